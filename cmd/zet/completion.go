@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/urfave/cli/v2"
+)
+
+// Shells of zet completion
+var shells = []string{"bash", "zsh", "fish"}
+
+// Completion returns the completion script of the shell, the scripts call
+// zet with --generate-bash-completion to complete the commands, the flags
+// and the zettels
+func Completion(shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return bashCompletion, nil
+	case "zsh":
+		return zshCompletion, nil
+	case "fish":
+		return fishCompletion, nil
+	}
+
+	return "", usageErrorf("error: invalid shell %q, expected %s", shell, strings.Join(shells, ", "))
+}
+
+// completeZettels completes the first n arguments of a command with the
+// zettels returned by list, by the value given. Flags are completed as usual
+// and nothing is printed on errors, so the shell falls back to files.
+func completeZettels(n int, list func(context.Context) ([]*model.Zettel, error), value func(*model.Zettel) string) cli.BashCompleteFunc {
+	return func(c *cli.Context) {
+		if completeFlags(c) || c.NArg() >= n {
+			return
+		}
+
+		zettels, err := list(c.Context)
+		if err != nil {
+			return
+		}
+
+		for _, zet := range zettels {
+			writeCandidate(c.App.Writer, value(zet), zet.Title)
+		}
+	}
+}
+
+// completeTrash completes the first argument with the ids of the trashed
+// zettels
+func completeTrash(zr repository.ZettelRepository) cli.BashCompleteFunc {
+	return func(c *cli.Context) {
+		if completeFlags(c) || c.NArg() > 0 {
+			return
+		}
+
+		trash, err := zr.ListTrash(c.Context)
+		if err != nil {
+			return
+		}
+
+		for _, t := range trash {
+			writeCandidate(c.App.Writer, t.ID, t.Title)
+		}
+	}
+}
+
+// completeValues completes the argument at the position with the values
+func completeValues(position int, values ...string) cli.BashCompleteFunc {
+	return func(c *cli.Context) {
+		if completeFlags(c) || c.NArg() != position {
+			return
+		}
+
+		for _, v := range values {
+			writeCandidate(c.App.Writer, v, "")
+		}
+	}
+}
+
+// completeFlags completes the flags when the word being completed starts with
+// a dash, like the default completion of urfave/cli
+func completeFlags(c *cli.Context) bool {
+	if len(os.Args) < 3 || !strings.HasPrefix(os.Args[len(os.Args)-2], "-") {
+		return false
+	}
+
+	cli.DefaultCompleteWithFlags(c.Command)(c)
+	return true
+}
+
+// writeCandidate writes a completion, zsh also gets its description
+func writeCandidate(w io.Writer, value string, description string) {
+	if os.Getenv("_CLI_ZSH_AUTOCOMPLETE_HACK") == "1" && description != "" {
+		fmt.Fprintf(w, "%s:%s\n", strings.ReplaceAll(value, ":", `\:`), description)
+		return
+	}
+	fmt.Fprintln(w, value)
+}
+
+func bySlug(zet *model.Zettel) string {
+	return zet.Slug
+}
+
+func byPath(zet *model.Zettel) string {
+	return zet.Path
+}
+
+const bashCompletion = `# bash completion for zet, add to ~/.bashrc:
+#   source <(zet completion bash)
+
+_zet_completion() {
+  local cur opts
+  COMPREPLY=()
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  if [[ "$cur" == "-"* ]]; then
+    opts=$( "${COMP_WORDS[@]:0:$COMP_CWORD}" "$cur" --generate-bash-completion 2>/dev/null )
+  else
+    opts=$( "${COMP_WORDS[@]:0:$COMP_CWORD}" --generate-bash-completion 2>/dev/null )
+  fi
+  local IFS=$'\n'
+  COMPREPLY=( $(compgen -W "${opts}" -- "${cur}") )
+  return 0
+}
+
+complete -o bashdefault -o default -F _zet_completion zet
+`
+
+const zshCompletion = `#compdef zet
+# zsh completion for zet, add to ~/.zshrc:
+#   source <(zet completion zsh)
+
+_zet() {
+  local -a opts
+  local cur
+  cur=${words[-1]}
+  if [[ "$cur" == "-"* ]]; then
+    opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${words[@]:0:#words[@]-1} ${cur} --generate-bash-completion 2>/dev/null)}")
+  else
+    opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${words[@]:0:#words[@]-1} --generate-bash-completion 2>/dev/null)}")
+  fi
+
+  if [[ "${opts[1]}" != "" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+
+compdef _zet zet
+`
+
+const fishCompletion = `# fish completion for zet, add to ~/.config/fish/config.fish:
+#   zet completion fish | source
+
+function __zet_complete
+  set -l args (commandline -opc)
+  set -l cur (commandline -ct)
+  set -l opts
+  if string match -q -- '-*' $cur
+    set opts ($args $cur --generate-bash-completion 2>/dev/null)
+  else
+    set opts ($args --generate-bash-completion 2>/dev/null)
+  end
+
+  if test (count $opts) -eq 0
+    __fish_complete_path $cur
+  else
+    printf '%s\n' $opts
+  end
+end
+
+complete -c zet -f -a '(__zet_complete)'
+`
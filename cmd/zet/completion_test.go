@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestCompletion(t *testing.T) {
+	// the completion looks at os.Args for flags
+	args := os.Args
+	os.Args = []string{"zet", "render", "--generate-bash-completion"}
+	t.Cleanup(func() {
+		os.Args = args
+	})
+
+	complete := func(t *testing.T, fn cli.BashCompleteFunc, args ...string) []string {
+		set := flag.NewFlagSet("test", flag.ContinueOnError)
+		require.Equal(t, set.Parse(args), nil, "failed to parse the args")
+
+		var b strings.Builder
+		c := cli.NewContext(&cli.App{Writer: &b}, set, nil)
+		fn(c)
+		return strings.Fields(b.String())
+	}
+
+	t.Run("slugs -> paths -> trash -> values", func(t *testing.T) {
+		// the completions list every zettel, start from an empty database
+		cleanup(t)
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z2 := createZet(t, zr, "A title two")
+
+		got := complete(t, completeZettels(1, zr.ListAll, bySlug))
+		assert.Equal(t, len(got), 2, "every zettel should be completed")
+		assert.Equal(t, strings.Join(got, " ") == z1.Slug+" "+z2.Slug || strings.Join(got, " ") == z2.Slug+" "+z1.Slug, true, "the slugs should be completed")
+
+		got = complete(t, completeZettels(1, zr.ListAll, byPath), z1.Slug)
+		assert.Equal(t, len(got), 0, "the arguments past n should not be completed")
+
+		got = complete(t, completeZettels(2, zr.ListFleet, byPath), z1.Slug)
+		assert.Equal(t, len(got), 2, "the second argument should be completed")
+		assert.Equal(t, strings.HasSuffix(got[0], ".md"), true, "the paths should be completed")
+
+		_, err := Remove(zr, z2.Path)
+		require.Equal(t, err, nil, "failed to remove z2")
+
+		got = complete(t, completeTrash(zr))
+		assert.Equal(t, strings.Join(got, " "), z2.ID, "the trashed ids should be completed")
+
+		got = complete(t, completeValues(1, "1", "2"), z1.Slug)
+		assert.Equal(t, strings.Join(got, " "), "1 2", "the values should be completed at their position")
+	})
+
+	t.Run("commands", func(t *testing.T) {
+		zr, _ := startup(t)
+		format := ErrorFormatText
+		app := newApp(zr, &format)
+
+		// the commands taking a zettel complete it
+		for _, name := range []string{"open", "remove", "archive", "merge", "split", "render", "permanent", "lint", "fleet", "save", "log", "diff", "show"} {
+			cmd := app.Command(name)
+			require.Equal(t, cmd != nil, true, name+" should be a command")
+			assert.Equal(t, cmd.BashComplete != nil, true, name+" should complete the zettels")
+		}
+	})
+
+	t.Run("scripts", func(t *testing.T) {
+		for _, shell := range shells {
+			script, err := Completion(shell)
+			require.Equal(t, err, nil, "failed to get the script of "+shell)
+			assert.Equal(t, strings.Contains(script, "--generate-bash-completion"), true, "the script should call zet")
+		}
+
+		_, err := Completion("tcsh")
+		code, _ := exitCode(err)
+		assert.Equal(t, code, ExitUsage, "unknown shells should be a usage error")
+	})
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+// Kinds of issues reported by the doctor
+const (
+	IssueDuplicateID     = "duplicate-id"
+	IssueDuplicateSlug   = "duplicate-slug"
+	IssueInvalidFilename = "invalid-filename"
+	IssueMissingTitle    = "missing-title"
+	IssueMissingFile     = "missing-file"
+	IssueIndexDrift      = "index-drift"
+	IssueOrphanedHistory = "orphaned-history"
+)
+
+type Issue struct {
+	Kind    string `json:"kind"`
+	ID      string `json:"id,omitempty"`
+	Slug    string `json:"slug,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+	Fixed   bool   `json:"fixed"`
+}
+
+// Doctor checks that the filesystem and the database agree with each other.
+// When fix is true, the safe repairs are applied:
+//
+// - rows whose file no longer exists are removed
+// - the full text index is rebuilt
+// - orphaned history events are removed
+//
+// Duplicates, invalid filenames and missing titles need a human to decide, so
+// those are only reported.
+func Doctor(zr repository.ZettelRepository, fix bool) ([]*Issue, error) {
+	ctx := context.Background()
+	cfg := zr.Config()
+
+	issues := []*Issue{}
+
+	// the filenames are ids of the configured format
+	ids, err := repository.NewIDGenerator(cfg.IDFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	//
+	// Filesystem checks
+	//
+
+	paths := append(fs.List(cfg.FleetRoot), fs.List(cfg.PermanentRoot)...)
+	paths = append(paths, fs.List(cfg.ArchiveRoot)...)
+
+	byID := make(map[string][]string)
+	for _, path := range paths {
+		if fs.IsDir(path) {
+			continue
+		}
+
+		name := filepath.Base(path)
+		id := strings.TrimSuffix(name, filepath.Ext(name))
+
+		if filepath.Ext(name) != ".md" || !ids.Valid(id) {
+			issues = append(issues, &Issue{
+				Kind:    IssueInvalidFilename,
+				Path:    path,
+				Message: fmt.Sprintf("%s is not a valid zettel id", name),
+			})
+			continue
+		}
+
+		byID[id] = append(byID[id], path)
+
+		lines, err := fs.ReadLines(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) == 0 || !strings.HasPrefix(lines[0], "# ") {
+			issues = append(issues, &Issue{
+				Kind:    IssueMissingTitle,
+				ID:      id,
+				Path:    path,
+				Message: "first line is not a '# ' title",
+			})
+		}
+	}
+
+	for _, path := range paths {
+		name := filepath.Base(path)
+		id := strings.TrimSuffix(name, filepath.Ext(name))
+		if len(byID[id]) > 1 {
+			issues = append(issues, &Issue{
+				Kind:    IssueDuplicateID,
+				ID:      id,
+				Path:    path,
+				Message: fmt.Sprintf("id is shared by %d files", len(byID[id])),
+			})
+		}
+	}
+
+	//
+	// Database checks
+	//
+
+	duplicates, err := zr.DuplicateSlugs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, zet := range duplicates {
+		issues = append(issues, &Issue{
+			Kind:    IssueDuplicateSlug,
+			ID:      zet.ID,
+			Slug:    zet.Slug,
+			Path:    zet.Path,
+			Message: fmt.Sprintf("slug [[%s]] is used by more than one zettel", zet.Slug),
+		})
+	}
+
+	zettels, err := zr.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	archived, err := zr.ListArchive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zettels = append(zettels, archived...)
+
+	var missing []*model.Zettel
+	for _, zet := range zettels {
+		if !fs.Exists(zet.Path) {
+			missing = append(missing, zet)
+		}
+	}
+
+	if fix && len(missing) > 0 {
+		if err := zr.RemoveBulk(ctx, missing...); err != nil {
+			return nil, err
+		}
+	}
+	for _, zet := range missing {
+		issues = append(issues, &Issue{
+			Kind:    IssueMissingFile,
+			ID:      zet.ID,
+			Slug:    zet.Slug,
+			Path:    zet.Path,
+			Message: "file no longer exists",
+			Fixed:   fix,
+		})
+	}
+
+	if err := zr.CheckIndex(ctx); err != nil {
+		issue := &Issue{
+			Kind:    IssueIndexDrift,
+			Message: fmt.Sprintf("full text index is out of sync: %v", err),
+		}
+		if fix {
+			if err := zr.RebuildIndex(ctx); err != nil {
+				return nil, err
+			}
+			issue.Fixed = true
+		}
+		issues = append(issues, issue)
+	}
+
+	orphans, err := zr.OrphanedHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if fix && len(orphans) > 0 {
+		if err := zr.RemoveOrphanedHistory(ctx); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range orphans {
+		issues = append(issues, &Issue{
+			Kind:    IssueOrphanedHistory,
+			ID:      id,
+			Message: "history event points to a zettel that does not exist",
+			Fixed:   fix,
+		})
+	}
+
+	return issues, nil
+}
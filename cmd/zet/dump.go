@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+// Dump writes every zettel, with its content, links, history, reviews and
+// tags, as newline delimited JSON. It returns the number of zettels written.
+func Dump(zr repository.ZettelRepository, w io.Writer) (int, error) {
+	dumps, err := zr.Dump(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	enc := json.NewEncoder(w)
+	for _, d := range dumps {
+		if err := enc.Encode(d); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(dumps), nil
+}
+
+// RestoreDump rebuilds the files and the database from the output of Dump. It
+// fails without changing anything if any of the zettels, or their files,
+// already exists.
+func RestoreDump(zr repository.ZettelRepository, r io.Reader) ([]*model.Zettel, error) {
+	var dumps []*model.Dump
+
+	scanner := bufio.NewScanner(r)
+	// the content of a zettel can be longer than the default token size
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		d := &model.Dump{}
+		if err := json.Unmarshal(scanner.Bytes(), d); err != nil {
+			return nil, fmt.Errorf("error: invalid dump at line %d: %w", line, err)
+		}
+		dumps = append(dumps, d)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// the files are created before the rows are committed, if any of them
+	// fails the created ones are removed and nothing is inserted
+	var created []string
+	files := func(zettels []*model.Zettel) error {
+		for _, zet := range zettels {
+			content := zet.Content
+			if !strings.HasSuffix(content, "\n") {
+				content += "\n"
+			}
+
+			if err := fs.CreateExclusive(zet.Path, content); err != nil {
+				return err
+			}
+			created = append(created, zet.Path)
+		}
+		return nil
+	}
+
+	zettels, err := zr.LoadDump(context.Background(), dumps, files)
+	if err != nil {
+		for _, path := range created {
+			os.Remove(path)
+		}
+		return nil, err
+	}
+
+	return zettels, nil
+}
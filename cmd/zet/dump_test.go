@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+func TestDump(t *testing.T) {
+	t.Run("dump -> wipe -> restore -> dump is the same", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, cfg := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z2 := createZet(t, zr, "A title two")
+		z2.WriteLine(fmt.Sprintf("Linked to [[%s]] #idea", z1.Slug))
+		z2 = saveZet(t, zr, z2)
+
+		_, err := Permanent(zr, z1.Path, false)
+		require.Equal(t, err, nil, "failed to make z1 permanent")
+		_, err = RateReview(zr, z1.ID, 4)
+		require.Equal(t, err, nil, "failed to review z1")
+		// z3 is scheduled without being reviewed
+		z3 := createZet(t, zr, "A title three")
+		_, err = Permanent(zr, z3.Path, false)
+		require.Equal(t, err, nil, "failed to make z3 permanent")
+		_, err = zr.ScheduleReviews(context.Background())
+		require.Equal(t, err, nil, "failed to schedule the reviews")
+
+		var before bytes.Buffer
+		n, err := Dump(zr, &before)
+		require.Equal(t, err, nil, "failed to dump")
+		require.Equal(t, n >= 2, true, "dump should have the zettels")
+
+		lines := strings.Split(strings.TrimSpace(before.String()), "\n")
+		var d1, d2, d3 *model.Dump
+		for _, line := range lines {
+			d := &model.Dump{}
+			require.Equal(t, json.Unmarshal([]byte(line), d), nil, "every line should be a zettel")
+			switch d.ID {
+			case z1.ID:
+				d1 = d
+			case z2.ID:
+				d2 = d
+			case z3.ID:
+				d3 = d
+			}
+		}
+		require.NotEqual(t, d1, nil, "z1 should be dumped")
+		require.NotEqual(t, d2, nil, "z2 should be dumped")
+		require.NotEqual(t, d1.Review, nil, "z1 review should be dumped")
+		assert.Equal(t, d1.Review.Repetitions, 1, "z1 review should be dumped")
+		assert.NotEqual(t, d1.Review.ReviewedAt, nil, "z1 review should be dumped")
+		assert.Equal(t, d2.Review == nil, true, "z2 is not reviewed")
+		require.NotEqual(t, d3.Review, nil, "z3 review should be dumped")
+		assert.Equal(t, d3.Review.ReviewedAt == nil, true, "z3 was never reviewed")
+		assert.Equal(t, strings.Join(d2.Links, " "), z1.ID, "z2 links should be dumped")
+		assert.Equal(t, strings.Join(d2.Tags, " "), "idea", "z2 tags should be dumped")
+		require.Equal(t, len(d2.Events), 2, "z2 events should be dumped")
+		assert.Equal(t, d2.Events[1].Kind, model.EventSaved, "z2 events should be dumped oldest first")
+		assert.Equal(t, strings.HasPrefix(d2.Path, "fleet/"), true, "paths should be relative to the root")
+
+		// conflicts are refused without changing anything
+		_, err = RestoreDump(zr, bytes.NewReader(before.Bytes()))
+		assert.NotEqual(t, err, nil, "restoring over existing zettels should fail")
+
+		err = zr.Reset(context.Background())
+		require.Equal(t, err, nil, "failed to reset database")
+		require.Equal(t, fs.RemoveAll(cfg.FleetRoot), nil, "failed to remove fleet root")
+		require.Equal(t, fs.RemoveAll(cfg.PermanentRoot), nil, "failed to remove permanent root")
+		require.Equal(t, fs.Mkdir(cfg.FleetRoot), nil, "failed to create fleet root")
+		require.Equal(t, fs.Mkdir(cfg.PermanentRoot), nil, "failed to create permanent root")
+
+		zettels, err := RestoreDump(zr, bytes.NewReader(before.Bytes()))
+		require.Equal(t, err, nil, "failed to restore")
+		assert.Equal(t, len(zettels), n, "every zettel should be restored")
+
+		content, err := fs.Read(z2.Path)
+		require.Equal(t, err, nil, "z2 file should be restored")
+		assert.Equal(t, strings.Contains(content, "[["+z1.Slug+"]]"), true, "z2 content should be restored")
+
+		var after bytes.Buffer
+		_, err = Dump(zr, &after)
+		require.Equal(t, err, nil, "failed to dump again")
+		assert.Equal(t, after.String(), before.String(), "round trip should be lossless")
+	})
+
+	t.Run("failed restores leave nothing behind", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, cfg := startup(t)
+		ctx := context.Background()
+
+		dump := func(id string, path string) string {
+			return fmt.Sprintf(`{"id":"%s","slug":"a-title-%s","title":"A title %s","path":"%s","type":"fleet","createdAt":"2024-07-01T10:00:00.000Z","updatedAt":"2024-07-01T10:00:00.000Z","content":"# A title %s","links":[],"events":[{"kind":"created","createdAt":"2024-07-01T10:00:00.000Z"}],"tags":[]}`, id, id, id, path, id)
+		}
+
+		// the file of the second zettel already exists
+		require.Equal(t, fs.CreateExclusive(cfg.FleetRoot+"/2.md", "# Another title\n"), nil, "failed to create the file")
+
+		in := dump("1", "fleet/1.md") + "\n" + dump("2", "fleet/2.md") + "\n"
+		_, err := RestoreDump(zr, strings.NewReader(in))
+		assert.NotEqual(t, err, nil, "restoring over an existing file should fail")
+		assert.Equal(t, fs.Exists(cfg.FleetRoot+"/1.md"), false, "the created files should be removed")
+
+		events, err := zr.Events(ctx, &model.EventFilter{})
+		require.Equal(t, err, nil, "failed to query the events")
+		assert.Equal(t, len(events), 0, "the events should be rolled back")
+
+		zettels, err := zr.ListAll(ctx)
+		require.Equal(t, err, nil, "failed to list the zettels")
+		assert.Equal(t, len(zettels), 0, "the zettels should be rolled back")
+
+		for _, path := range []string{"../../escaped.md", "/tmp/escaped.md", "fleet/../../escaped.md", ".trash/1.md"} {
+			_, err = RestoreDump(zr, strings.NewReader(dump("1", path)))
+			assert.Equal(t, errors.Is(err, repository.ErrInvalidZettel), true, "paths outside of the roots should be refused: "+path)
+		}
+		assert.Equal(t, fs.Exists(cfg.Root+"/../escaped.md"), false, "nothing should be written outside of the roots")
+	})
+}
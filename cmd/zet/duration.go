@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseDuration is like time.ParseDuration but also accepts days and weeks,
+// like 30d or 2w, which are the usual units when talking about notes.
+func parseDuration(s string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+
+	for suffix, unit := range units {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(s, suffix))
+			if err != nil {
+				return 0, usageErrorf("error: invalid duration %q", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/database"
+	"github.com/odas0r/zet/pkg/git"
+	"github.com/urfave/cli/v2"
+)
+
+// Exit codes of zet, documented in the README
+const (
+	ExitOK = 0
+	// any other error, e.g. the filesystem or git failing
+	ExitError = 1
+	// missing arguments, invalid flags or invalid argument values
+	ExitUsage = 2
+	// no zettel matches the path, id, slug or title
+	ExitNotFound = 3
+	// more than one zettel has the title
+	ExitAmbiguous = 4
+	// the file is not a zettel, or the zettel cannot be saved
+	ExitInvalid = 5
+	// the zettel, file or alias already exists, or git has conflicts
+	ExitConflict = 6
+	// the zettel breaks the promotion rules, see zet lint
+	ExitNotReady = 7
+	// the database is locked by another zet, try again
+	ExitBusy = 8
+)
+
+// Formats of the errors written to stderr, see --error-format
+const (
+	ErrorFormatText = "text"
+	ErrorFormatJSON = "json"
+)
+
+// UsageError is a missing argument or an invalid argument or flag value
+type UsageError struct {
+	Message string
+}
+
+func (e *UsageError) Error() string {
+	return e.Message
+}
+
+func usageErrorf(format string, args ...any) error {
+	return &UsageError{Message: fmt.Sprintf(format, args...)}
+}
+
+// usageError turns the error of parsing an argument into a usage error
+func usageError(err error) error {
+	var usageErr *UsageError
+	if errors.As(err, &usageErr) {
+		return err
+	}
+	return &UsageError{Message: err.Error()}
+}
+
+// missingArgs is the usage error of a command called without its arguments
+func missingArgs(c *cli.Context) error {
+	usage := c.Command.ArgsUsage
+	if usage == "" {
+		usage = "<zettel>"
+	}
+	return usageErrorf("error: missing arguments, usage: zet %s %s", c.Command.FullName(), usage)
+}
+
+// withUndo adds the errors of undoing a failed change to its error, which
+// stays the one errors.Is and exitCode see
+func withUndo(err error, undo ...error) error {
+	for _, e := range undo {
+		if e != nil {
+			err = fmt.Errorf("%w, and failed to undo it: %v", err, e)
+		}
+	}
+	return err
+}
+
+// jsonError is the error written to stderr with --error-format json
+type jsonError struct {
+	Error string `json:"error"`
+	// one of not_found, ambiguous, invalid, conflict, not_ready, busy, usage
+	// or error
+	Kind string `json:"kind"`
+	Code int    `json:"code"`
+	// the broken rules of a not_ready error
+	Data any `json:"data,omitempty"`
+}
+
+// exitCode maps an error to the exit code of zet and its kind
+func exitCode(err error) (int, string) {
+	var usageErr *UsageError
+	var lintErr *LintError
+
+	switch {
+	case err == nil:
+		return ExitOK, ""
+	case errors.As(err, &usageErr),
+		errors.Is(err, repository.ErrNoZettel),
+		errors.Is(err, repository.ErrInvalidRating),
+		errors.Is(err, repository.ErrInvalidKind),
+		errors.Is(err, repository.ErrInvalidQuery):
+		return ExitUsage, "usage"
+	case errors.Is(err, repository.ErrZettelNotFound), errors.Is(err, repository.ErrNothingDue):
+		return ExitNotFound, "not_found"
+	case errors.Is(err, repository.ErrZettelAmbiguous):
+		return ExitAmbiguous, "ambiguous"
+	case errors.Is(err, repository.ErrInvalidZettel):
+		return ExitInvalid, "invalid"
+	case errors.Is(err, repository.ErrZettelConflict), errors.Is(err, os.ErrExist), errors.Is(err, git.ErrConflict):
+		return ExitConflict, "conflict"
+	case errors.As(err, &lintErr), errors.Is(err, ErrNotReady):
+		return ExitNotReady, "not_ready"
+	case database.IsBusy(err):
+		return ExitBusy, "busy"
+	}
+
+	var exitErr cli.ExitCoder
+	if errors.As(err, &exitErr) && exitErr.ExitCode() != 0 {
+		return exitErr.ExitCode(), "error"
+	}
+
+	return ExitError, "error"
+}
+
+// reportError writes the error to w, a line of text or a JSON object, and
+// returns its exit code
+func reportError(w io.Writer, err error, format string) int {
+	code, kind := exitCode(err)
+	if code == ExitOK {
+		return code
+	}
+
+	if format != ErrorFormatJSON {
+		fmt.Fprintln(w, err)
+		return code
+	}
+
+	e := &jsonError{Error: err.Error(), Kind: kind, Code: code}
+	var lintErr *LintError
+	if errors.As(err, &lintErr) {
+		e.Data = lintErr.Violations
+	}
+	encoder := json.NewEncoder(w)
+	// keep the <zettel> of the usage readable
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(e); err != nil {
+		fmt.Fprintln(w, e.Error)
+	}
+
+	return code
+}
+
+// exitWithError writes the error to stderr, in the format of --error-format,
+// and exits with its code
+func exitWithError(format string, err error) {
+	if err == nil {
+		return
+	}
+
+	os.Exit(reportError(os.Stderr, err, format))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/git"
+)
+
+func TestExitCode(t *testing.T) {
+	t.Run("errors -> exit codes", func(t *testing.T) {
+		cases := []struct {
+			err  error
+			code int
+		}{
+			{nil, ExitOK},
+			{fmt.Errorf("error: failed to X: %w", os.ErrPermission), ExitError},
+			{usageErrorf("error: invalid rating %s", "x"), ExitUsage},
+			{repository.ErrInvalidRating, ExitUsage},
+			{fmt.Errorf("%w: fts5: syntax error", repository.ErrInvalidQuery), ExitUsage},
+			{fmt.Errorf("error: failed to open zettel: %w", repository.ErrZettelNotFound), ExitNotFound},
+			{fmt.Errorf("%w: [[A title]] matches a, b", repository.ErrZettelAmbiguous), ExitAmbiguous},
+			{fmt.Errorf("%w: /tmp/not-a-zettel.md", repository.ErrInvalidZettel), ExitInvalid},
+			{fmt.Errorf("error: failed to merge: %w", repository.ErrZettelConflict), ExitConflict},
+			{fmt.Errorf("error: failed to move: %w", os.ErrExist), ExitConflict},
+			{fmt.Errorf("%w: resolve a.md first", git.ErrConflict), ExitConflict},
+			{&LintError{Violations: []*Violation{{Rule: RuleTag}}}, ExitNotReady},
+		}
+
+		for _, c := range cases {
+			code, _ := exitCode(c.err)
+			assert.Equal(t, code, c.code, fmt.Sprintf("wrong exit code for %v", c.err))
+		}
+	})
+
+	t.Run("text -> json", func(t *testing.T) {
+		err := fmt.Errorf("error: failed to render zettel: %w", repository.ErrZettelNotFound)
+
+		var b strings.Builder
+		code := reportError(&b, err, ErrorFormatText)
+		assert.Equal(t, code, ExitNotFound, "the code should be returned")
+		assert.Equal(t, b.String(), "error: failed to render zettel: error: zettel not found\n", "the text should be the message")
+
+		b.Reset()
+		reportError(&b, usageErrorf("error: missing arguments, usage: zet render <zettel>"), ErrorFormatJSON)
+
+		var got jsonError
+		require.Equal(t, json.Unmarshal([]byte(b.String()), &got), nil, "the error should be a JSON object")
+		assert.Equal(t, got.Kind, "usage", "the kind should be set")
+		assert.Equal(t, got.Code, ExitUsage, "the code should be set")
+		assert.Equal(t, strings.Contains(b.String(), "<zettel>"), true, "the usage should not be escaped")
+
+		b.Reset()
+		reportError(&b, &LintError{Violations: []*Violation{{Rule: RuleTag, Message: "has no #tag"}}}, ErrorFormatJSON)
+		assert.Equal(t, strings.Contains(b.String(), `"data":[{`), true, "the broken rules should be the data")
+	})
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/fs"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// searchEntry is an entry of the search.json index of the html export
+type searchEntry struct {
+	Slug  string   `json:"slug"`
+	Title string   `json:"title"`
+	URL   string   `json:"url"`
+	Tags  []string `json:"tags"`
+	Text  string   `json:"text"`
+}
+
+type htmlZettel struct {
+	*model.Zettel
+	URL       string
+	Body      template.HTML
+	Tags      []string
+	Backlinks []*htmlZettel
+}
+
+type htmlTag struct {
+	Name    string
+	Zettels []*htmlZettel
+}
+
+var htmlTemplates = template.Must(template.New("layout").Parse(`{{define "header"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.}}</title>
+<style>
+body { max-width: 42rem; margin: 2rem auto; padding: 0 1rem; font-family: sans-serif; line-height: 1.5; }
+nav a { margin-right: 1rem; }
+.tags a { margin-right: .5rem; }
+.backlinks { border-top: 1px solid #ccc; margin-top: 2rem; }
+</style>
+</head>
+<body>
+<nav><a href="index.html">Index</a><a href="tags.html">Tags</a></nav>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "zettel"}}{{template "header" .Title}}<article>
+<h1>{{.Title}}</h1>
+{{if .Tags}}<p class="tags">{{range .Tags}}<a href="tags.html#{{.}}">#{{.}}</a>{{end}}</p>{{end}}
+{{.Body}}
+</article>
+{{if .Backlinks}}<section class="backlinks">
+<h2>Backlinks</h2>
+<ul>
+{{range .Backlinks}}<li><a href="{{.URL}}">{{.Title}}</a></li>
+{{end}}</ul>
+</section>
+{{end}}{{template "footer"}}{{end}}
+
+{{define "tags"}}{{template "header" "Tags"}}<h1>Tags</h1>
+{{range .}}<section id="{{.Name}}">
+<h2>#{{.Name}}</h2>
+<ul>
+{{range .Zettels}}<li><a href="{{.URL}}">{{.Title}}</a></li>
+{{end}}</ul>
+</section>
+{{end}}{{template "footer"}}{{end}}
+
+{{define "index"}}{{template "header" "Index"}}<h1>Index</h1>
+<input id="search" type="search" placeholder="Search" autofocus>
+<ul id="zettels">
+{{range .}}<li><a href="{{.URL}}">{{.Title}}</a></li>
+{{end}}</ul>
+<script>
+const input = document.getElementById("search");
+const list = document.getElementById("zettels");
+const all = list.innerHTML;
+fetch("search.json").then((res) => res.json()).then((entries) => {
+  input.addEventListener("input", () => {
+    const terms = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    if (terms.length === 0) {
+      list.innerHTML = all;
+      return;
+    }
+    list.replaceChildren(...entries
+      .filter((e) => terms.every((t) => (e.title + " " + e.tags.join(" ") + " " + e.text).toLowerCase().includes(t)))
+      .map((e) => {
+        const li = document.createElement("li");
+        const a = document.createElement("a");
+        a.href = e.url;
+        a.textContent = e.title;
+        li.appendChild(a);
+        return li;
+      }));
+  });
+});
+</script>
+{{template "footer"}}{{end}}
+`))
+
+// ExportReport is the directory the zettels were exported to, with the
+// exported zettels
+type ExportReport struct {
+	Out     string          `json:"out"`
+	Zettels []*model.Zettel `json:"zettels"`
+}
+
+// ExportHTML renders the permanent zettels, and the fleet ones when fleet is
+// true, as a static site in the out directory:
+//
+// - <slug>.html for every zettel, with its ![[embeds]] expanded, its [[links]]
+// pointing to the other pages and a backlinks section
+// - tags.html with the zettels of every #tag
+// - search.json, an index for client side search
+// - index.html with every zettel and a search box
+//
+// Links to zettels that are not exported are rendered as plain text.
+func ExportHTML(zr repository.ZettelRepository, out string, fleet bool) (*ExportReport, error) {
+	ctx := context.Background()
+
+	var (
+		zettels []*model.Zettel
+		err     error
+	)
+	if fleet {
+		zettels, err = zr.ListAll(ctx)
+	} else {
+		zettels, err = zr.ListPermanent(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(zettels, func(i, j int) bool {
+		return strings.ToLower(zettels[i].Title) < strings.ToLower(zettels[j].Title)
+	})
+
+	pages := make(map[string]*htmlZettel, len(zettels))
+	for _, zet := range zettels {
+		pages[zet.ID] = &htmlZettel{
+			Zettel: zet,
+			URL:    zet.Slug + ".html",
+			Tags:   zet.Tags(),
+		}
+	}
+
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return nil, err
+	}
+
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+	tags := make(map[string]*htmlTag)
+	entries := []*searchEntry{}
+
+	for _, zet := range zettels {
+		page := pages[zet.ID]
+
+		// the embeds are expanded, the links are left to exportBody
+		rendered := *zet
+		rendered.Content = renderEmbeds(ctx, zr, zet, MaxEmbedDepth)
+
+		body := exportBody(ctx, zr, &rendered, func(target *model.Zettel) string {
+			if p, ok := pages[target.ID]; ok {
+				return p.URL
+			}
+			return ""
+		})
+
+		var buf bytes.Buffer
+		if err := md.Convert([]byte(body), &buf); err != nil {
+			return nil, err
+		}
+		page.Body = template.HTML(buf.String())
+
+		backlinks, err := zr.Backlinks(ctx, &model.Zettel{ID: zet.ID})
+		if err != nil {
+			return nil, err
+		}
+		for _, backlink := range backlinks {
+			if p, ok := pages[backlink.ID]; ok {
+				page.Backlinks = append(page.Backlinks, p)
+			}
+		}
+
+		for _, name := range page.Tags {
+			tag, ok := tags[name]
+			if !ok {
+				tag = &htmlTag{Name: name}
+				tags[name] = tag
+			}
+			tag.Zettels = append(tag.Zettels, page)
+		}
+
+		entries = append(entries, &searchEntry{
+			Slug:  zet.Slug,
+			Title: zet.Title,
+			URL:   page.URL,
+			Tags:  page.Tags,
+			Text:  exportBody(ctx, zr, &rendered, func(*model.Zettel) string { return "" }),
+		})
+	}
+
+	for _, zet := range zettels {
+		if err := writeTemplate(filepath.Join(out, pages[zet.ID].URL), "zettel", pages[zet.ID]); err != nil {
+			return nil, err
+		}
+	}
+
+	tagList := make([]*htmlTag, 0, len(tags))
+	for _, tag := range tags {
+		tagList = append(tagList, tag)
+	}
+	sort.Slice(tagList, func(i, j int) bool {
+		return tagList[i].Name < tagList[j].Name
+	})
+
+	if err := writeTemplate(filepath.Join(out, "tags.html"), "tags", tagList); err != nil {
+		return nil, err
+	}
+
+	index := make([]*htmlZettel, len(zettels))
+	for i, zet := range zettels {
+		index[i] = pages[zet.ID]
+	}
+	if err := writeTemplate(filepath.Join(out, "index.html"), "index", index); err != nil {
+		return nil, err
+	}
+
+	bytes, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.WriteAtomic(filepath.Join(out, "search.json"), string(bytes)); err != nil {
+		return nil, err
+	}
+
+	return &ExportReport{Out: out, Zettels: zettels}, nil
+}
+
+// exportBody returns the content of the zettel without its title, with every
+// [[link]] replaced by a markdown link to the url returned by href. Links
+// without an url, or that cannot be resolved, are left as plain text.
+func exportBody(ctx context.Context, zr repository.ZettelRepository, zet *model.Zettel, href func(*model.Zettel) string) string {
+	lines := strings.Split(zet.Content, "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "# ") {
+		lines = lines[1:]
+	}
+
+	rewriteLinks(ctx, zr, lines, func(text string, target *model.Zettel) string {
+		if target == nil {
+			return text
+		}
+		if target.Title != "" && text == target.Slug {
+			text = target.Title
+		}
+
+		url := href(target)
+		if url == "" {
+			return text
+		}
+
+		return "[" + text + "](" + url + ")"
+	})
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// rewriteLinks replaces every [[link]] of the lines with the result of
+// replace, called with the text of the link and the zettel it resolves to, or
+// nil when it cannot be resolved. Fenced code blocks are kept as is.
+func rewriteLinks(ctx context.Context, zr repository.ZettelRepository, lines []string, replace func(text string, target *model.Zettel) string) {
+	fenced := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+
+		links := findWikilinks(line)
+		if len(links) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, link := range links {
+			b.WriteString(line[last:link.Start])
+			last = link.End
+
+			target := model.NewLink(link.Text)
+			if err := zr.Resolve(ctx, target); err != nil {
+				target = nil
+			}
+
+			b.WriteString(replace(link.Text, target))
+		}
+		b.WriteString(line[last:])
+
+		lines[i] = b.String()
+	}
+}
+
+// ExportMarkdown writes every zettel to the out directory as a plain markdown
+// note, that other tools can open without the database:
+//
+// - the file is named after the title, "<title>.md"
+// - a YAML front matter holds the id, type, creation date and tags
+// - [[slug]] links are rewritten to [[Title]]
+func ExportMarkdown(zr repository.ZettelRepository, out string) (*ExportReport, error) {
+	ctx := context.Background()
+
+	zettels, err := zr.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(zettels, func(i, j int) bool {
+		return zettels[i].CreatedAt.T.Before(zettels[j].CreatedAt.T)
+	})
+
+	// id -> filename without the extension, unique ignoring the case
+	names := make(map[string]string, len(zettels))
+	taken := make(map[string]bool, len(zettels))
+	for _, zet := range zettels {
+		base := markdownFilename(zet.Title)
+		name := base
+		for i := 2; taken[strings.ToLower(name)]; i++ {
+			name = fmt.Sprintf("%s %d", base, i)
+		}
+		taken[strings.ToLower(name)] = true
+		names[zet.ID] = name
+	}
+
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return nil, err
+	}
+
+	for _, zet := range zettels {
+		lines := strings.Split(zet.Content, "\n")
+
+		rewriteLinks(ctx, zr, lines, func(text string, target *model.Zettel) string {
+			if target == nil {
+				return "[[" + text + "]]"
+			}
+
+			name := names[target.ID]
+			if name == "" {
+				return "[[" + text + "]]"
+			}
+			if _, heading, ok := strings.Cut(text, "#"); ok {
+				name += "#" + heading
+			}
+			if names[target.ID] != target.Title {
+				name += "|" + target.Title
+			}
+
+			return "[[" + name + "]]"
+		})
+
+		fm := model.FrontMatter{
+			"id":      {zet.ID},
+			"type":    {zet.Type},
+			"created": {zet.CreatedAt.T.UTC().Format(time.RFC3339)},
+			"tags":    zet.Tags(),
+		}
+
+		content := fm.String([]string{"id", "type", "created", "tags"}, "tags") + strings.Join(lines, "\n")
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+
+		if err := fs.WriteAtomic(filepath.Join(out, names[zet.ID]+".md"), content); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ExportReport{Out: out, Zettels: zettels}, nil
+}
+
+// markdownFilename replaces the characters of the title that are not allowed
+// in filenames, or in the [[links]] of other tools
+func markdownFilename(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|#^[]`, r) {
+			return '-'
+		}
+		return r
+	}, title)
+
+	name = strings.Trim(strings.TrimSpace(name), ".")
+	if name == "" {
+		return "untitled"
+	}
+
+	return name
+}
+
+func writeTemplate(path string, name string, data any) error {
+	var buf bytes.Buffer
+	if err := htmlTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+
+	return fs.WriteAtomic(path, buf.String())
+}
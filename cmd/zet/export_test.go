@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/model"
+)
+
+func TestExportHTML(t *testing.T) {
+	t.Run("permanent zettels -> html pages, tags, search index", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+		out := t.TempDir()
+
+		z1 := createZet(t, zr, "A title one")
+		z2 := createZet(t, zr, "A title two")
+		z3 := createZet(t, zr, "A title three")
+
+		z2.WriteLine(fmt.Sprintf("Linked to [[%s]] and [[%s]] #idea", z1.Slug, z3.Slug))
+		z2 = saveZet(t, zr, z2)
+
+		_, err := Permanent(zr, z1.Path, false)
+		require.Equal(t, err, nil, "failed to make z1 permanent")
+		z2, err = Permanent(zr, z2.Path, false)
+		require.Equal(t, err, nil, "failed to make z2 permanent")
+
+		report, err := ExportHTML(zr, out, false)
+		require.Equal(t, err, nil, "failed to export")
+		assert.Equal(t, len(report.Zettels), 2, "only permanent zettels should be exported")
+		assert.Equal(t, report.Out, out, "the directory should be reported")
+
+		page, err := os.ReadFile(filepath.Join(out, z2.Slug+".html"))
+		require.Equal(t, err, nil, "z2 page should exist")
+		assert.Equal(t, strings.Contains(string(page), `<a href="`+z1.Slug+`.html">`), true, "link to z1 should be relative")
+		assert.Equal(t, strings.Contains(string(page), z3.Slug+".html"), false, "fleet zettel should not be linked")
+		assert.Equal(t, strings.Contains(string(page), `href="tags.html#idea"`), true, "tag should be linked")
+
+		page, err = os.ReadFile(filepath.Join(out, z1.Slug+".html"))
+		require.Equal(t, err, nil, "z1 page should exist")
+		assert.Equal(t, strings.Contains(string(page), "Backlinks"), true, "z1 should have backlinks")
+		assert.Equal(t, strings.Contains(string(page), `<a href="`+z2.Slug+`.html">`), true, "z2 should be a backlink")
+
+		_, err = os.Stat(filepath.Join(out, z3.Slug+".html"))
+		assert.Equal(t, os.IsNotExist(err), true, "fleet zettel should not be exported")
+
+		tags, err := os.ReadFile(filepath.Join(out, "tags.html"))
+		require.Equal(t, err, nil, "tags page should exist")
+		assert.Equal(t, strings.Contains(string(tags), `id="idea"`), true, "tag should be listed")
+
+		_, err = os.Stat(filepath.Join(out, "index.html"))
+		assert.Equal(t, err, nil, "index page should exist")
+
+		raw, err := os.ReadFile(filepath.Join(out, "search.json"))
+		require.Equal(t, err, nil, "search index should exist")
+
+		var entries []*searchEntry
+		err = json.Unmarshal(raw, &entries)
+		require.Equal(t, err, nil, "search index should be valid json")
+		require.Equal(t, len(entries), 2, "search index should have 2 entries")
+
+		_, err = ExportHTML(zr, out, true)
+		require.Equal(t, err, nil, "failed to export with fleet")
+
+		_, err = os.Stat(filepath.Join(out, z3.Slug+".html"))
+		assert.Equal(t, err, nil, "fleet zettel should be exported")
+	})
+}
+
+func TestExportMarkdown(t *testing.T) {
+	t.Run("zettels -> titled notes with front matter and title links", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+		out := t.TempDir()
+
+		z1 := createZet(t, zr, "A title one")
+		z2 := createZet(t, zr, "Why: a title two")
+
+		z2.WriteLine(fmt.Sprintf("Linked to [[%s]] #idea", z1.Slug))
+		z2 = saveZet(t, zr, z2)
+
+		_, err := ExportMarkdown(zr, out)
+		require.Equal(t, err, nil, "failed to export")
+
+		raw, err := os.ReadFile(filepath.Join(out, "Why- a title two.md"))
+		require.Equal(t, err, nil, "z2 should be named after its title")
+
+		fm, body := model.ParseFrontMatter(string(raw))
+		assert.Equal(t, fm.Get("id"), z2.ID, "id should be in the front matter")
+		assert.Equal(t, fm.Get("type"), "fleet", "type should be in the front matter")
+		assert.NotEqual(t, fm.Get("created"), "", "created should be in the front matter")
+		assert.Equal(t, strings.Join(fm["tags"], " "), "idea", "tags should be in the front matter")
+		assert.Equal(t, strings.HasPrefix(body, "# Why: a title two\n"), true, "title should be kept")
+		assert.Equal(t, strings.Contains(body, "[[A title one]]"), true, "link should use the title")
+
+		_, err = os.Stat(filepath.Join(out, "A title one.md"))
+		assert.Equal(t, err, nil, "z1 should be exported")
+	})
+}
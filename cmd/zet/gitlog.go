@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/fs"
+	"github.com/odas0r/zet/pkg/git"
+)
+
+// GitLog returns the commits that changed the zettel, newest first, following
+// its moves between fleet and permanent
+func GitLog(zr repository.ZettelRepository, arg string) ([]*git.Commit, error) {
+	repo, path, err := zettelRepo(zr, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	commits, err := repo.Log(path)
+	if err != nil {
+		return nil, err
+	}
+	if commits == nil {
+		commits = []*git.Commit{}
+	}
+
+	return commits, nil
+}
+
+// GitDiff returns the changes of the zettel since the revision, HEAD when
+// empty
+func GitDiff(zr repository.ZettelRepository, arg string, rev string) (string, error) {
+	repo, path, err := zettelRepo(zr, arg)
+	if err != nil {
+		return "", err
+	}
+
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	return repo.Diff(path, rev)
+}
+
+// GitShow returns the content of a zettel at a revision, given as
+// <zettel>@<rev>. The first @ splits them, revisions like HEAD@{1} have
+// their own.
+func GitShow(zr repository.ZettelRepository, arg string) (string, error) {
+	i := strings.Index(arg, "@")
+	if i <= 0 || i == len(arg)-1 {
+		return "", usageErrorf("error: expected <zettel>@<rev>, got %s", arg)
+	}
+
+	repo, path, err := zettelRepo(zr, arg[:i])
+	if err != nil {
+		return "", err
+	}
+
+	return repo.Show(path, arg[i+1:])
+}
+
+// zettelRepo returns the repository of the zettelkasten and the path of the
+// zettel in it
+func zettelRepo(zr repository.ZettelRepository, arg string) (*git.Repo, string, error) {
+	zet, err := findZettel(zr, arg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	repo, err := git.Open(zr.Config().Root)
+	if err != nil {
+		return nil, "", err
+	}
+
+	path, err := repo.Rel(zet.Path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return repo, path, nil
+}
+
+// findZettel returns the zettel given by its path, id, slug or title
+func findZettel(zr repository.ZettelRepository, arg string) (*model.Zettel, error) {
+	ctx := context.Background()
+
+	if fs.Exists(arg) && !fs.IsDir(arg) {
+		path, err := filepath.Abs(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		zet := &model.Zettel{Path: path}
+		if err := zr.Get(ctx, zet); err == nil {
+			return zet, nil
+		}
+	}
+
+	zet := &model.Zettel{ID: arg}
+	err := zr.Get(ctx, zet)
+	if err == nil {
+		return zet, nil
+	}
+	if !errors.Is(err, repository.ErrZettelNotFound) {
+		return nil, err
+	}
+
+	zet = model.NewLink(arg)
+	if err := zr.Resolve(ctx, zet); err != nil {
+		return nil, err
+	}
+
+	return zet, nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/fs"
+	"github.com/odas0r/zet/pkg/git"
+)
+
+type GitChange struct {
+	// created, edited, removed or moved
+	Kind  string `json:"kind"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+type GitSyncReport struct {
+	Committed bool         `json:"committed"`
+	Message   string       `json:"message,omitempty"`
+	Changes   []*GitChange `json:"changes"`
+	Pulled    bool         `json:"pulled"`
+	Pushed    bool         `json:"pushed"`
+	// zettels left with merge conflicts, to be resolved by hand
+	Conflicts []*GitChange `json:"conflicts"`
+}
+
+// GitSync commits the changes of the zettelkasten with a message listing the
+// created, edited, removed and moved zettels, merges the upstream branch,
+// syncs the index with the pulled zettels and pushes.
+//
+// When the merge stops with conflicts it is left in progress, the conflicting
+// zettels are reported with an error wrapping git.ErrConflict. The next sync
+// commits the merge once the conflict markers are removed from them.
+func GitSync(zr repository.ZettelRepository) (*GitSyncReport, error) {
+	cfg := zr.Config()
+
+	repo, err := git.Open(cfg.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	// the removed zettels are moved to the trash, they must not be committed
+	// as moved zettels
+	if trash, err := repo.Rel(cfg.TrashRoot); err == nil {
+		repo.Exclude = append(repo.Exclude, trash)
+	}
+
+	report := &GitSyncReport{
+		Changes:   []*GitChange{},
+		Conflicts: []*GitChange{},
+	}
+
+	// a previous merge might still have conflicts, once their markers are
+	// gone the merge is committed with the other changes
+	conflicts, err := repo.Conflicts()
+	if err != nil {
+		return nil, err
+	}
+
+	var unresolved []string
+	for _, path := range conflicts {
+		content, err := fs.Read(filepath.Join(repo.Dir, path))
+		if err != nil || strings.Contains(content, "\n<<<<<<< ") || strings.HasPrefix(content, "<<<<<<< ") {
+			unresolved = append(unresolved, path)
+		}
+	}
+	if len(unresolved) > 0 {
+		report.Conflicts = gitConflicts(repo, unresolved)
+		return report, fmt.Errorf("%w: resolve %s first", git.ErrConflict, strings.Join(unresolved, ", "))
+	}
+
+	changes, err := repo.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		report.Changes = append(report.Changes, gitChange(repo, change))
+	}
+
+	// a resolved merge is committed even if it ends up with no changes
+	if len(report.Changes) > 0 || len(conflicts) > 0 {
+		report.Message = gitCommitMessage(report.Changes)
+		if err := repo.CommitAll(report.Message); err != nil {
+			return nil, err
+		}
+		report.Committed = true
+	}
+
+	if !repo.HasUpstream() {
+		return report, nil
+	}
+
+	conflicts, err = repo.Pull()
+	if errors.Is(err, git.ErrConflict) {
+		report.Conflicts = gitConflicts(repo, conflicts)
+		return report, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.Pulled = true
+
+	// the pulled zettels are not in the index yet
+	if _, err := Sync(zr); err != nil {
+		return nil, err
+	}
+
+	if err := repo.Push(); err != nil {
+		return nil, err
+	}
+	report.Pushed = true
+
+	return report, nil
+}
+
+func gitChange(repo *git.Repo, change *git.Change) *GitChange {
+	c := &GitChange{Path: change.Path, From: change.From}
+
+	switch change.Status {
+	case git.StatusAdded:
+		c.Kind = "created"
+	case git.StatusDeleted:
+		c.Kind = "removed"
+	case git.StatusRenamed:
+		c.Kind = "moved"
+	default:
+		c.Kind = "edited"
+	}
+
+	if filepath.Ext(change.Path) != ".md" {
+		return c
+	}
+
+	// removed files are only in the last commit
+	if change.Status == git.StatusDeleted {
+		if content, err := repo.Run("show", "HEAD:"+change.Path); err == nil {
+			c.Title = gitTitle(content)
+		}
+		return c
+	}
+
+	if content, err := fs.Read(filepath.Join(repo.Dir, change.Path)); err == nil {
+		c.Title = gitTitle(content)
+	}
+
+	return c
+}
+
+// gitConflicts returns the conflicting files with the titles of the zettels
+func gitConflicts(repo *git.Repo, paths []string) []*GitChange {
+	conflicts := make([]*GitChange, len(paths))
+	for i, path := range paths {
+		conflicts[i] = &GitChange{Kind: "conflict", Path: path}
+
+		// the title of our side, the working tree has the conflict markers
+		if content, err := repo.Run("show", ":2:"+path); err == nil {
+			conflicts[i].Title = gitTitle(content)
+		}
+	}
+
+	return conflicts
+}
+
+// gitCommitMessage summarizes the changes in the subject, like "zet: 2
+// created, 1 edited", and lists them in the body
+func gitCommitMessage(changes []*GitChange) string {
+	kinds := []string{"created", "edited", "moved", "removed"}
+
+	counts := make(map[string]int)
+	for _, c := range changes {
+		counts[c.Kind]++
+	}
+
+	var summary []string
+	for _, kind := range kinds {
+		if counts[kind] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+
+	sorted := make([]*GitChange, len(changes))
+	copy(sorted, changes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return indexOf(kinds, sorted[i].Kind) < indexOf(kinds, sorted[j].Kind)
+	})
+
+	if len(summary) == 0 {
+		summary = append(summary, "merge")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "zet: %s\n\n", strings.Join(summary, ", "))
+	for _, c := range sorted {
+		name := c.Path
+		if c.Title != "" {
+			name = fmt.Sprintf("%s (%s)", c.Title, c.Path)
+		}
+		if c.From != "" {
+			name = fmt.Sprintf("%s from %s", name, c.From)
+		}
+		fmt.Fprintf(&b, "%s: %s\n", c.Kind, name)
+	}
+	fmt.Fprintf(&b, "\nSynced at %s\n", time.Now().Format(time.RFC3339))
+
+	return b.String()
+}
+
+// gitTitle returns the title of a zettel from its content
+func gitTitle(content string) string {
+	line, _, _ := strings.Cut(content, "\n")
+	if !strings.HasPrefix(line, "# ") {
+		return ""
+	}
+	return strings.TrimPrefix(line, "# ")
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return len(values)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/config"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/internal/test/sqltest"
+	"github.com/odas0r/zet/pkg/fs"
+	"github.com/odas0r/zet/pkg/git"
+)
+
+func TestGitSync(t *testing.T) {
+	t.Run("commit -> pull -> index -> push -> conflict", func(t *testing.T) {
+		zr, cfg := gitStartup(t)
+		gitEnv(t)
+
+		bare := t.TempDir()
+		clone := filepath.Join(t.TempDir(), "clone")
+
+		gitRun(t, bare, "init", "--quiet", "--bare", "--initial-branch=main")
+		gitRun(t, cfg.Root, "init", "--quiet", "--initial-branch=main")
+		gitRun(t, cfg.Root, "remote", "add", "origin", bare)
+
+		// the test database lives in the root
+		err := os.WriteFile(filepath.Join(cfg.Root, ".gitignore"), []byte("zettel_test.db*\n"), 0644)
+		require.Equal(t, err, nil, "failed to write .gitignore")
+		gitRun(t, cfg.Root, "add", ".gitignore")
+		gitRun(t, cfg.Root, "commit", "--quiet", "--message", "init")
+
+		z1 := createZet(t, zr, "A title one")
+
+		report, err := GitSync(zr)
+		require.Equal(t, err, nil, "failed to sync without upstream")
+		assert.Equal(t, report.Committed, true, "changes should be committed")
+		assert.Equal(t, report.Pulled, false, "nothing to pull without upstream")
+		assert.Equal(t, strings.HasPrefix(report.Message, "zet: 1 created"), true, "subject should summarize the changes")
+		assert.Equal(t, strings.Contains(report.Message, "created: A title one (fleet/"+z1.ID+".md)"), true, "body should list the zettels")
+
+		gitRun(t, cfg.Root, "push", "--quiet", "--set-upstream", "origin", "main")
+		gitRun(t, filepath.Dir(clone), "clone", "--quiet", bare, clone)
+
+		// a zettel created on another machine
+		remote := "20000101000000000"
+		err = os.WriteFile(filepath.Join(clone, "fleet", remote+".md"), []byte("# A remote title\n"), 0644)
+		require.Equal(t, err, nil, "failed to write remote zettel")
+		gitRun(t, clone, "add", "--all")
+		gitRun(t, clone, "commit", "--quiet", "--message", "remote")
+		gitRun(t, clone, "push", "--quiet")
+
+		// a local edit, committed and pushed along the pulled changes
+		err = z1.WriteLine("A local line")
+		require.Equal(t, err, nil, "failed to edit z1")
+
+		report, err = GitSync(zr)
+		require.Equal(t, err, nil, "failed to sync")
+		assert.Equal(t, strings.HasPrefix(report.Message, "zet: 1 edited"), true, "edit should be committed")
+		assert.Equal(t, report.Pulled, true, "remote changes should be pulled")
+		assert.Equal(t, report.Pushed, true, "local changes should be pushed")
+
+		err = zr.Get(context.Background(), &model.Zettel{ID: remote})
+		assert.Equal(t, err, nil, "pulled zettel should be indexed")
+
+		// both sides edit the same line
+		gitRun(t, clone, "pull", "--quiet", "--no-rebase")
+		path := filepath.Join(clone, "fleet", z1.ID+".md")
+		content, err := fs.Read(path)
+		require.Equal(t, err, nil, "failed to read z1 in the clone")
+		err = os.WriteFile(path, []byte(strings.Replace(content, "A local line", "A remote line", 1)), 0644)
+		require.Equal(t, err, nil, "failed to edit z1 in the clone")
+		gitRun(t, clone, "commit", "--quiet", "--all", "--message", "remote edit")
+		gitRun(t, clone, "push", "--quiet")
+
+		z1.Content = strings.Replace(z1.Content, "A local line", "Another local line", 1)
+		err = z1.Write()
+		require.Equal(t, err, nil, "failed to edit z1")
+
+		report, err = GitSync(zr)
+		assert.Equal(t, errors.Is(err, git.ErrConflict), true, "conflict should be reported")
+		require.Equal(t, len(report.Conflicts), 1, "z1 should be in conflict")
+		assert.Equal(t, report.Conflicts[0].Path, "fleet/"+z1.ID+".md", "z1 path should be reported")
+		assert.Equal(t, report.Conflicts[0].Title, "A title one", "z1 title should be reported")
+
+		_, err = GitSync(zr)
+		assert.Equal(t, errors.Is(err, git.ErrConflict), true, "unresolved conflicts should stop the next sync")
+
+		z1.Content = strings.Replace(content, "A local line", "A resolved line", 1)
+		err = z1.Write()
+		require.Equal(t, err, nil, "failed to resolve z1")
+
+		report, err = GitSync(zr)
+		require.Equal(t, err, nil, "resolved conflicts should be committed")
+		assert.Equal(t, report.Pushed, true, "merge should be pushed")
+	})
+
+	t.Run("remove -> the trash is not committed", func(t *testing.T) {
+		zr, cfg := gitStartup(t)
+		gitEnv(t)
+
+		gitRun(t, cfg.Root, "init", "--quiet", "--initial-branch=main")
+		err := os.WriteFile(filepath.Join(cfg.Root, ".gitignore"), []byte("zettel_test.db*\n"), 0644)
+		require.Equal(t, err, nil, "failed to write .gitignore")
+
+		z1 := createZet(t, zr, "A title one")
+		_, err = GitSync(zr)
+		require.Equal(t, err, nil, "failed to commit z1")
+
+		_, err = Remove(zr, z1.Path)
+		require.Equal(t, err, nil, "failed to remove z1")
+
+		report, err := GitSync(zr)
+		require.Equal(t, err, nil, "failed to commit the removal")
+		require.Equal(t, len(report.Changes), 1, "only the removal should be committed")
+		assert.Equal(t, report.Changes[0].Kind, "removed", "z1 should be removed, not moved to the trash")
+		assert.Equal(t, report.Changes[0].Path, "fleet/"+z1.ID+".md", "z1 path should be reported")
+		assert.Equal(t, strings.HasPrefix(report.Message, "zet: 1 removed"), true, "removal should be committed")
+	})
+}
+
+func TestGitLog(t *testing.T) {
+	t.Run("edit -> permanent -> log, show and diff follow the move", func(t *testing.T) {
+		zr, cfg := gitStartup(t)
+		gitEnv(t)
+
+		gitRun(t, cfg.Root, "init", "--quiet", "--initial-branch=main")
+		err := os.WriteFile(filepath.Join(cfg.Root, ".gitignore"), []byte("zettel_test.db*\n"), 0644)
+		require.Equal(t, err, nil, "failed to write .gitignore")
+
+		z1 := createZet(t, zr, "A title one")
+		_, err = GitSync(zr)
+		require.Equal(t, err, nil, "failed to commit z1")
+
+		err = z1.WriteLine("An edited line")
+		require.Equal(t, err, nil, "failed to edit z1")
+		_, err = GitSync(zr)
+		require.Equal(t, err, nil, "failed to commit the edit")
+
+		z1, err = Permanent(zr, z1.Path, false)
+		require.Equal(t, err, nil, "failed to make z1 permanent")
+		report, err := GitSync(zr)
+		require.Equal(t, err, nil, "failed to commit the move")
+		assert.Equal(t, strings.HasPrefix(report.Message, "zet: 1 moved"), true, "move should be committed")
+
+		commits, err := GitLog(zr, z1.Slug)
+		require.Equal(t, err, nil, "failed to get the log")
+		require.Equal(t, len(commits), 3, "every commit of z1 should be listed")
+		assert.Equal(t, commits[0].Path, "permanent/"+filepath.Base(z1.Path), "newest commit should have the moved path")
+		assert.Equal(t, commits[2].Path, "fleet/"+z1.ID+".md", "oldest commit should have the fleet path")
+
+		content, err := GitShow(zr, z1.ID+"@"+commits[2].Hash)
+		require.Equal(t, err, nil, "failed to show the first revision")
+		assert.Equal(t, strings.Contains(content, "An edited line"), false, "first revision should not have the edit")
+
+		// the revision has an @ of its own, the edit before the move
+		content, err = GitShow(zr, z1.ID+"@HEAD@{1}")
+		require.Equal(t, err, nil, "failed to show a reflog revision")
+		assert.Equal(t, strings.Contains(content, "An edited line"), true, "HEAD@{1} should have the edit")
+
+		diff, err := GitDiff(zr, z1.Path, commits[2].Hash)
+		require.Equal(t, err, nil, "failed to get the diff")
+		assert.Equal(t, strings.Contains(diff, "+An edited line"), true, "diff should have the edit")
+	})
+}
+
+// gitStartup is startup with a root of its own, so git never runs in the
+// shared root of the other tests
+func gitStartup(t *testing.T) (repository.ZettelRepository, *config.Config) {
+	cfg := config.New(t.TempDir())
+	db := sqltest.CreateDatabase(t, cfg)
+	zr, err := repository.NewZettelRepository(db, cfg)
+	require.Equal(t, err, nil, "failed to create the repository")
+	return zr, cfg
+}
+
+// gitEnv isolates git from the user configuration
+func gitEnv(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "zet")
+	t.Setenv("GIT_AUTHOR_EMAIL", "zet@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "zet")
+	t.Setenv("GIT_COMMITTER_EMAIL", "zet@example.com")
+}
+
+func gitRun(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	out, err := cmd.CombinedOutput()
+	require.Equal(t, err, nil, "git "+strings.Join(args, " ")+": "+string(out))
+}
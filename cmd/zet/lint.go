@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+)
+
+// Promotion rules, see config.PromotionRules
+const (
+	RuleMinWords         = "min-words"
+	RuleOutgoingLink     = "outgoing-link"
+	RuleTag              = "tag"
+	RuleBrokenLink       = "broken-link"
+	RulePlaceholderTitle = "placeholder-title"
+)
+
+var ErrNotReady = errors.New("error: zettel is not ready to be permanent")
+
+// Violation is a promotion rule the zettel breaks
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// LintError is returned by Permanent when the zettel breaks the promotion
+// rules, it wraps ErrNotReady
+type LintError struct {
+	Violations []*Violation
+}
+
+func (e *LintError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return fmt.Sprintf("%v: %s", ErrNotReady, strings.Join(messages, ", "))
+}
+
+func (e *LintError) Unwrap() error {
+	return ErrNotReady
+}
+
+// Lint returns the promotion rules the zettel given by its path, id, slug or
+// title breaks, reading its file so unsaved changes count
+func Lint(zr repository.ZettelRepository, arg string) ([]*Violation, error) {
+	zet, err := findZettel(zr, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return lintZettel(zr, zet)
+}
+
+func lintZettel(zr repository.ZettelRepository, zet *model.Zettel) ([]*Violation, error) {
+	ctx := context.Background()
+	cfg := zr.Config()
+	rules := cfg.Promotion
+
+	// the id of a permanent zettel is not its file name
+	id := zet.ID
+
+	zet = &model.Zettel{Path: zet.Path}
+	if err := zet.Read(cfg); err != nil {
+		return nil, err
+	}
+
+	violations := []*Violation{}
+
+	title := strings.ToLower(strings.TrimSpace(zet.Title))
+	if title == "" {
+		violations = append(violations, &Violation{Rule: RulePlaceholderTitle, Message: "title is empty"})
+	}
+	for _, placeholder := range rules.Placeholders {
+		if title != "" && title == strings.ToLower(placeholder) {
+			violations = append(violations, &Violation{
+				Rule:    RulePlaceholderTitle,
+				Message: fmt.Sprintf("title %q is a placeholder", zet.Title),
+			})
+		}
+	}
+
+	if rules.MinWords > 0 {
+		_, body, _ := strings.Cut(zet.Content, "\n")
+		if n := countWords(body); n < rules.MinWords {
+			violations = append(violations, &Violation{
+				Rule:    RuleMinWords,
+				Message: fmt.Sprintf("has %d words, at least %d are needed", n, rules.MinWords),
+			})
+		}
+	}
+
+	resolved := 0
+	for _, link := range zet.Links {
+		err := zr.Resolve(ctx, link)
+		if err == nil {
+			if link.ID != id {
+				resolved++
+			}
+			continue
+		}
+		if !isUnresolved(err) {
+			return nil, err
+		}
+
+		if rules.NoBrokenLinks {
+			violations = append(violations, &Violation{
+				Rule:    RuleBrokenLink,
+				Message: fmt.Sprintf("[[%s]] does not resolve to a zettel", linkText(link)),
+			})
+		}
+	}
+
+	if rules.RequireLink && resolved == 0 {
+		violations = append(violations, &Violation{Rule: RuleOutgoingLink, Message: "links to no other zettel"})
+	}
+
+	if rules.RequireTag && len(zet.Tags()) == 0 {
+		violations = append(violations, &Violation{Rule: RuleTag, Message: "has no #tag"})
+	}
+
+	return violations, nil
+}
+
+// linkText returns the text of an unresolved link, its title when it was
+// written as one
+func linkText(link *model.Zettel) string {
+	if link.Title != "" {
+		return link.Title
+	}
+	return link.Slug
+}
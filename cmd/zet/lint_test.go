@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/config"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+func TestLint(t *testing.T) {
+	t.Run("lint -> refused promotion -> fix -> promote", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, cfg := startup(t)
+
+		rules := `{"promotion": {"minWords": 5, "requireLink": true, "requireTag": true, "noBrokenLinks": true, "placeholders": ["draft"]}}`
+		path := filepath.Join(cfg.Root, config.File)
+		err := os.WriteFile(path, []byte(rules), 0644)
+		require.Equal(t, err, nil, "failed to write the config")
+		t.Cleanup(func() {
+			os.Remove(path)
+		})
+
+		err = cfg.Load()
+		require.Equal(t, err, nil, "failed to load the config")
+
+		z1 := createZet(t, zr, "Draft")
+		// saving refuses broken links, the file is linted as it is
+		z1.WriteLine("[[A missing zettel]]")
+
+		violations, err := Lint(zr, z1.ID)
+		require.Equal(t, err, nil, "failed to lint z1")
+
+		broken := make(map[string]bool)
+		for _, v := range violations {
+			broken[v.Rule] = true
+		}
+		assert.Equal(t, len(violations), 5, "every rule should be broken")
+		assert.Equal(t, broken[RulePlaceholderTitle], true, "placeholder titles should be reported")
+		assert.Equal(t, broken[RuleMinWords], true, "short zettels should be reported")
+		assert.Equal(t, broken[RuleBrokenLink], true, "broken links should be reported")
+		assert.Equal(t, broken[RuleOutgoingLink], true, "zettels without links should be reported")
+		assert.Equal(t, broken[RuleTag], true, "zettels without tags should be reported")
+
+		_, err = Permanent(zr, z1.Path, false)
+		assert.Equal(t, errors.Is(err, ErrNotReady), true, "z1 should not be promoted")
+
+		var lintErr *LintError
+		require.Equal(t, errors.As(err, &lintErr), true, "the violations should be returned")
+		assert.Equal(t, len(lintErr.Violations), 5, "every violation should be returned")
+
+		z2 := createZet(t, zr, "A title two")
+		content := fmt.Sprintf("# A title one\n\nA body long enough, linked to [[%s]] #tag\n", z2.Slug)
+		err = fs.WriteAtomic(z1.Path, content)
+		require.Equal(t, err, nil, "failed to fix z1")
+
+		violations, err = Lint(zr, z1.Path)
+		require.Equal(t, err, nil, "failed to lint z1")
+		assert.Equal(t, len(violations), 0, "z1 should be ready")
+
+		z1 = saveZet(t, zr, z1)
+		z1, err = Permanent(zr, z1.Path, false)
+		require.Equal(t, err, nil, "failed to make z1 permanent")
+		assert.Equal(t, z1.Type, "permanent", "z1 should be permanent")
+	})
+
+	t.Run("force skips the rules", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, cfg := startup(t)
+
+		_, err := Permanent(zr, createZet(t, zr, "Untitled").Path, false)
+		require.Equal(t, err, nil, "the default rules should check nothing")
+
+		cfg.Promotion.Placeholders = []string{"untitled"}
+		z1 := createZet(t, zr, "Untitled")
+
+		_, err = Permanent(zr, z1.Path, false)
+		assert.Equal(t, errors.Is(err, ErrNotReady), true, "z1 should not be promoted")
+		assert.Equal(t, strings.Contains(err.Error(), "placeholder"), true, "the violations should be in the message")
+
+		z1, err = Permanent(zr, z1.Path, true)
+		require.Equal(t, err, nil, "failed to force z1 permanent")
+		assert.Equal(t, z1.Type, "permanent", "z1 should be permanent")
+	})
+}
@@ -0,0 +1,596 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/gosimple/slug"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/fs"
+	"github.com/odas0r/zet/pkg/jsonrpc"
+)
+
+// LSP types, only the fields we use
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text,omitempty"`
+}
+
+type lspPositionParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Position     lspPosition     `json:"position"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspCompletionItem struct {
+	Label      string       `json:"label"`
+	Kind       int          `json:"kind"`
+	Detail     string       `json:"detail"`
+	FilterText string       `json:"filterText"`
+	TextEdit   *lspTextEdit `json:"textEdit"`
+}
+
+const (
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+
+	lspCompletionReference = 18
+)
+
+// wikilink is a [[link]] found in a line, start and end are byte offsets of
+// the opening and closing brackets.
+type wikilink struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// findWikilinks returns all the [[links]] of a line
+func findWikilinks(line string) []wikilink {
+	var links []wikilink
+
+	offset := 0
+	for {
+		s := strings.Index(line[offset:], "[[")
+		if s == -1 {
+			break
+		}
+		s += offset
+
+		e := strings.Index(line[s+2:], "]]")
+		if e == -1 {
+			break
+		}
+		e += s + 2
+
+		links = append(links, wikilink{Text: line[s+2 : e], Start: s, End: e + 2})
+		offset = e + 2
+	}
+
+	return links
+}
+
+// lspServer is a language server for the zettels, speaking LSP over stdio
+type lspServer struct {
+	zr   repository.ZettelRepository
+	conn *jsonrpc.Conn
+	exit context.CancelFunc
+
+	// text of the open documents by uri
+	docs map[string]string
+}
+
+// LSP serves the language server protocol on the given reader and writer
+// until the client sends exit or closes the stream.
+func LSP(ctx context.Context, zr repository.ZettelRepository, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := &lspServer{
+		zr:   zr,
+		conn: jsonrpc.NewConn(jsonrpc.NewHeaderStream(r, w)),
+		exit: cancel,
+		docs: make(map[string]string),
+	}
+
+	return s.conn.Serve(ctx, s.handle)
+}
+
+func (s *lspServer) handle(ctx context.Context, req *jsonrpc.Request) (any, error) {
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync": map[string]any{
+					"openClose": true,
+					"change":    1, // full
+					"save":      map[string]any{"includeText": false},
+				},
+				"completionProvider": map[string]any{
+					"triggerCharacters": []string{"["},
+				},
+				"definitionProvider": true,
+				"referencesProvider": true,
+				"hoverProvider":      true,
+				"renameProvider":     true,
+			},
+			"serverInfo": map[string]any{
+				"name": "zet",
+			},
+		}, nil
+	case "shutdown":
+		return nil, nil
+	case "exit":
+		s.exit()
+		return nil, nil
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		s.docs[params.TextDocument.URI] = params.TextDocument.Text
+		return nil, s.publishDiagnostics(ctx, params.TextDocument.URI)
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument   lspTextDocument `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.docs[params.TextDocument.URI] = params.ContentChanges[n-1].Text
+		}
+		return nil, s.publishDiagnostics(ctx, params.TextDocument.URI)
+	case "textDocument/didSave":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if _, err := Save(s.zr, uriToPath(params.TextDocument.URI)); err != nil {
+			s.conn.Notify("window/logMessage", map[string]any{
+				"type":    lspSeverityError,
+				"message": fmt.Sprintf("zet: failed to save zettel: %v", err),
+			})
+		}
+		return nil, s.publishDiagnostics(ctx, params.TextDocument.URI)
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.conn.Notify("textDocument/publishDiagnostics", map[string]any{
+			"uri":         params.TextDocument.URI,
+			"diagnostics": []lspDiagnostic{},
+		})
+	case "textDocument/completion":
+		return s.completion(ctx, req.Params)
+	case "textDocument/definition":
+		return s.definition(ctx, req.Params)
+	case "textDocument/references":
+		return s.references(ctx, req.Params)
+	case "textDocument/hover":
+		return s.hover(ctx, req.Params)
+	case "textDocument/rename":
+		return s.rename(ctx, req.Params)
+	default:
+		if req.IsNotification() {
+			return nil, nil
+		}
+		return nil, jsonrpc.Errorf(jsonrpc.CodeMethodNotFound, "method not found: %s", req.Method)
+	}
+}
+
+func (s *lspServer) completion(ctx context.Context, raw json.RawMessage) (any, error) {
+	var params lspPositionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+	}
+
+	items := []*lspCompletionItem{}
+	result := map[string]any{"isIncomplete": false, "items": items}
+
+	lines := s.lines(params.TextDocument.URI)
+	if params.Position.Line >= len(lines) {
+		return result, nil
+	}
+
+	line := lines[params.Position.Line]
+	cursor := byteOffset(line, params.Position.Character)
+	prefix := line[:cursor]
+
+	open := strings.LastIndex(prefix, "[[")
+	if open == -1 || strings.Contains(prefix[open:], "]]") {
+		return result, nil
+	}
+
+	// close the link unless it is already closed
+	closing := "]]"
+	if strings.HasPrefix(line[cursor:], "]]") {
+		closing = ""
+	}
+
+	zettels, err := s.zr.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, zet := range zettels {
+		items = append(items, &lspCompletionItem{
+			Label:      zet.Slug,
+			Kind:       lspCompletionReference,
+			Detail:     zet.Title,
+			FilterText: zet.Slug + " " + zet.Title,
+			TextEdit: &lspTextEdit{
+				Range: lspRange{
+					Start: lspPosition{Line: params.Position.Line, Character: utf16Offset(line, open+2)},
+					End:   params.Position,
+				},
+				NewText: zet.Slug + closing,
+			},
+		})
+	}
+	result["items"] = items
+
+	return result, nil
+}
+
+func (s *lspServer) definition(ctx context.Context, raw json.RawMessage) (any, error) {
+	var params lspPositionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+	}
+
+	link, _, ok := s.linkAt(params.TextDocument.URI, params.Position)
+	if !ok {
+		return nil, nil
+	}
+
+	if err := s.zr.Resolve(ctx, link); err != nil {
+		if isUnresolved(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &lspLocation{URI: pathToURI(link.Path)}, nil
+}
+
+func (s *lspServer) references(ctx context.Context, raw json.RawMessage) (any, error) {
+	var params lspPositionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+	}
+
+	target, err := s.target(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		if isUnresolved(err) {
+			return []*lspLocation{}, nil
+		}
+		return nil, err
+	}
+
+	backlinks, err := s.zr.Backlinks(ctx, &model.Zettel{ID: target.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	locations := []*lspLocation{}
+	for _, zet := range backlinks {
+		uri := pathToURI(zet.Path)
+		for _, r := range s.linksTo(uri, target) {
+			locations = append(locations, &lspLocation{URI: uri, Range: r})
+		}
+	}
+
+	return locations, nil
+}
+
+func (s *lspServer) hover(ctx context.Context, raw json.RawMessage) (any, error) {
+	var params lspPositionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+	}
+
+	link, r, ok := s.linkAt(params.TextDocument.URI, params.Position)
+	if !ok {
+		return nil, nil
+	}
+
+	if err := s.zr.Resolve(ctx, link); err != nil {
+		if isUnresolved(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	value := "**" + link.Title + "**"
+	if paragraph := link.FirstParagraph(); paragraph != "" {
+		value += "\n\n" + paragraph
+	}
+
+	return map[string]any{
+		"contents": map[string]any{
+			"kind":  "markdown",
+			"value": value,
+		},
+		"range": r,
+	}, nil
+}
+
+// rename renames the title of the zettel under the cursor, or of the current
+// document, and rewrites the links of every backlink to the new slug.
+func (s *lspServer) rename(ctx context.Context, raw json.RawMessage) (any, error) {
+	var params struct {
+		lspPositionParams
+		NewName string `json:"newName"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+	}
+
+	title := strings.TrimSpace(params.NewName)
+	if title == "" {
+		return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "the new name cannot be empty")
+	}
+
+	target, err := s.target(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		if isUnresolved(err) {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+		}
+		return nil, err
+	}
+
+	newSlug := slug.Make(title)
+
+	owner := &model.Zettel{Slug: newSlug}
+	err = s.zr.Get(ctx, owner)
+	if err == nil && owner.ID != target.ID {
+		return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "[[%s]] is already used by %s", newSlug, owner.Path)
+	}
+	if err != nil && !errors.Is(err, repository.ErrZettelNotFound) {
+		return nil, err
+	}
+
+	changes := make(map[string][]lspTextEdit)
+
+	targetURI := pathToURI(target.Path)
+	lines := s.lines(targetURI)
+	if len(lines) > 0 {
+		changes[targetURI] = append(changes[targetURI], lspTextEdit{
+			Range: lspRange{
+				End: lspPosition{Line: 0, Character: utf16Offset(lines[0], len(lines[0]))},
+			},
+			NewText: "# " + title,
+		})
+	}
+
+	backlinks, err := s.zr.Backlinks(ctx, &model.Zettel{ID: target.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, zet := range backlinks {
+		uri := pathToURI(zet.Path)
+		for _, r := range s.linksTo(uri, target) {
+			changes[uri] = append(changes[uri], lspTextEdit{
+				Range:   r,
+				NewText: "[[" + newSlug + "]]",
+			})
+		}
+	}
+
+	return map[string]any{"changes": changes}, nil
+}
+
+func (s *lspServer) publishDiagnostics(ctx context.Context, uri string) error {
+	diagnostics := []lspDiagnostic{}
+
+	for i, line := range s.lines(uri) {
+		for _, l := range findWikilinks(line) {
+			r := lspRange{
+				Start: lspPosition{Line: i, Character: utf16Offset(line, l.Start)},
+				End:   lspPosition{Line: i, Character: utf16Offset(line, l.End)},
+			}
+
+			if strings.TrimSpace(l.Text) == "" {
+				diagnostics = append(diagnostics, lspDiagnostic{
+					Range:    r,
+					Severity: lspSeverityError,
+					Source:   "zet",
+					Message:  "empty link",
+				})
+				continue
+			}
+
+			err := s.zr.Resolve(ctx, model.NewLink(l.Text))
+			switch {
+			case err == nil:
+			case errors.Is(err, repository.ErrZettelAmbiguous):
+				diagnostics = append(diagnostics, lspDiagnostic{
+					Range:    r,
+					Severity: lspSeverityWarning,
+					Source:   "zet",
+					Message:  err.Error(),
+				})
+			case isUnresolved(err):
+				diagnostics = append(diagnostics, lspDiagnostic{
+					Range:    r,
+					Severity: lspSeverityError,
+					Source:   "zet",
+					Message:  fmt.Sprintf("broken link [[%s]]", l.Text),
+				})
+			default:
+				return err
+			}
+		}
+	}
+
+	return s.conn.Notify("textDocument/publishDiagnostics", map[string]any{
+		"uri":         uri,
+		"diagnostics": diagnostics,
+	})
+}
+
+// target returns the zettel of the link under the cursor, or the zettel of the
+// document if the cursor is not on a link.
+func (s *lspServer) target(ctx context.Context, uri string, pos lspPosition) (*model.Zettel, error) {
+	if link, _, ok := s.linkAt(uri, pos); ok {
+		if err := s.zr.Resolve(ctx, link); err != nil {
+			return nil, err
+		}
+		return link, nil
+	}
+
+	zet := &model.Zettel{Path: uriToPath(uri)}
+	if err := s.zr.Get(ctx, zet); err != nil {
+		return nil, err
+	}
+
+	return zet, nil
+}
+
+// linkAt returns the link under the cursor and its range
+func (s *lspServer) linkAt(uri string, pos lspPosition) (*model.Zettel, lspRange, bool) {
+	lines := s.lines(uri)
+	if pos.Line >= len(lines) {
+		return nil, lspRange{}, false
+	}
+
+	line := lines[pos.Line]
+	cursor := byteOffset(line, pos.Character)
+
+	for _, l := range findWikilinks(line) {
+		if cursor >= l.Start && cursor <= l.End && strings.TrimSpace(l.Text) != "" {
+			r := lspRange{
+				Start: lspPosition{Line: pos.Line, Character: utf16Offset(line, l.Start)},
+				End:   lspPosition{Line: pos.Line, Character: utf16Offset(line, l.End)},
+			}
+			return model.NewLink(l.Text), r, true
+		}
+	}
+
+	return nil, lspRange{}, false
+}
+
+// linksTo returns the ranges of the links of a document that point to the
+// given zettel
+func (s *lspServer) linksTo(uri string, target *model.Zettel) []lspRange {
+	var ranges []lspRange
+
+	for i, line := range s.lines(uri) {
+		for _, l := range findWikilinks(line) {
+			link := model.NewLink(l.Text)
+			if link.Slug != target.Slug && !strings.EqualFold(link.Title, target.Title) {
+				continue
+			}
+			ranges = append(ranges, lspRange{
+				Start: lspPosition{Line: i, Character: utf16Offset(line, l.Start)},
+				End:   lspPosition{Line: i, Character: utf16Offset(line, l.End)},
+			})
+		}
+	}
+
+	return ranges
+}
+
+// lines returns the lines of an open document, or of the file on disk
+func (s *lspServer) lines(uri string) []string {
+	text, ok := s.docs[uri]
+	if !ok {
+		var err error
+		text, err = fs.Read(uriToPath(uri))
+		if err != nil {
+			return nil
+		}
+	}
+
+	return strings.Split(text, "\n")
+}
+
+func isUnresolved(err error) bool {
+	return errors.Is(err, repository.ErrZettelNotFound) ||
+		errors.Is(err, repository.ErrNoZettel) ||
+		errors.Is(err, repository.ErrZettelAmbiguous)
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return u.Path
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// utf16Offset converts a byte offset of a line to the utf-16 offset used by
+// LSP positions
+func utf16Offset(line string, offset int) int {
+	if offset > len(line) {
+		offset = len(line)
+	}
+	return len(utf16.Encode([]rune(line[:offset])))
+}
+
+// byteOffset converts an utf-16 offset of a line to a byte offset
+func byteOffset(line string, character int) int {
+	n := 0
+	for i, r := range line {
+		if n >= character {
+			return i
+		}
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return len(line)
+}
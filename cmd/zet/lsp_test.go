@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/pkg/jsonrpc"
+)
+
+func TestLSP(t *testing.T) {
+	t.Run("diagnostics -> definition -> references -> hover -> rename", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z1.Content = "# A title one\n\nThe first paragraph.\n\nThe second one."
+		require.Equal(t, z1.Write(), nil, "failed to write z1")
+		saveZet(t, zr, z1)
+
+		z2 := createZet(t, zr, "A title two")
+		z2.Content = fmt.Sprintf("# A title two\n\nLinked to [[%s]]", z1.Slug)
+		require.Equal(t, z2.Write(), nil, "failed to write z2")
+		saveZet(t, zr, z2)
+
+		uri := pathToURI(z2.Path)
+
+		var in bytes.Buffer
+		stream := jsonrpc.NewHeaderStream(nil, &in)
+		send := func(id int, method string, params any) {
+			raw, _ := json.Marshal(params)
+			req := &jsonrpc.Request{JSONRPC: jsonrpc.Version, Method: method, Params: raw}
+			if id > 0 {
+				req.ID = json.RawMessage(fmt.Sprint(id))
+			}
+			msg, _ := json.Marshal(req)
+			stream.Write(msg)
+		}
+
+		// unsaved changes of the buffer
+		text := fmt.Sprintf("# A title two\n\nLinked to [[%s]] and [[broken]]", z1.Slug)
+		position := map[string]any{
+			"textDocument": map[string]any{"uri": uri},
+			"position":     map[string]any{"line": 2, "character": 13},
+		}
+
+		send(1, "initialize", map[string]any{})
+		send(0, "textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "text": text},
+		})
+		send(2, "textDocument/definition", position)
+		send(3, "textDocument/hover", position)
+		send(4, "textDocument/completion", map[string]any{
+			"textDocument": map[string]any{"uri": uri},
+			"position":     map[string]any{"line": 2, "character": 12},
+		})
+		send(5, "textDocument/rename", map[string]any{
+			"textDocument": map[string]any{"uri": pathToURI(z1.Path)},
+			"position":     map[string]any{"line": 0, "character": 0},
+			"newName":      "A renamed title",
+		})
+		send(6, "shutdown", nil)
+		send(0, "exit", nil)
+
+		var out bytes.Buffer
+		err := LSP(context.Background(), zr, &in, &out)
+		require.Equal(t, err, nil, "language server failed")
+
+		messages := make(map[string]json.RawMessage)
+		reader := jsonrpc.NewHeaderStream(&out, nil)
+		for {
+			msg, err := reader.Read()
+			if err != nil {
+				break
+			}
+			var m struct {
+				ID     json.RawMessage `json:"id"`
+				Method string          `json:"method"`
+				Params json.RawMessage `json:"params"`
+				Result json.RawMessage `json:"result"`
+			}
+			json.Unmarshal(msg, &m)
+			if m.Method != "" {
+				messages[m.Method] = m.Params
+			} else {
+				messages[string(m.ID)] = m.Result
+			}
+		}
+
+		var diagnostics struct {
+			Diagnostics []lspDiagnostic `json:"diagnostics"`
+		}
+		json.Unmarshal(messages["textDocument/publishDiagnostics"], &diagnostics)
+		require.Equal(t, len(diagnostics.Diagnostics), 1, "broken link should be reported")
+		assert.Equal(t, diagnostics.Diagnostics[0].Message, "broken link [[broken]]", "diagnostic should be the broken link")
+
+		var location lspLocation
+		json.Unmarshal(messages["2"], &location)
+		assert.Equal(t, location.URI, pathToURI(z1.Path), "definition should be z1")
+
+		var hover struct {
+			Contents struct {
+				Value string `json:"value"`
+			} `json:"contents"`
+		}
+		json.Unmarshal(messages["3"], &hover)
+		assert.Equal(t, hover.Contents.Value, "**A title one**\n\nThe first paragraph.", "hover should show the first paragraph")
+
+		var completion struct {
+			Items []lspCompletionItem `json:"items"`
+		}
+		json.Unmarshal(messages["4"], &completion)
+		slugs := make(map[string]bool)
+		for _, item := range completion.Items {
+			slugs[item.Label] = true
+		}
+		assert.Equal(t, slugs[z1.Slug], true, "should complete z1")
+		assert.Equal(t, slugs[z2.Slug], true, "should complete z2")
+
+		var rename struct {
+			Changes map[string][]lspTextEdit `json:"changes"`
+		}
+		json.Unmarshal(messages["5"], &rename)
+		require.Equal(t, len(rename.Changes[pathToURI(z1.Path)]), 1, "title of z1 should be renamed")
+		assert.Equal(t, rename.Changes[pathToURI(z1.Path)][0].NewText, "# A renamed title", "title should be replaced")
+		require.Equal(t, len(rename.Changes[uri]), 1, "backlink of z2 should be rewritten")
+		assert.Equal(t, rename.Changes[uri][0].NewText, "[[a-renamed-title]]", "link should use the new slug")
+	})
+}
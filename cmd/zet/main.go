@@ -1,12 +1,15 @@
 package main
 
 import (
-	"encoding/json"
+	"bufio"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/odas0r/zet/internal/config"
 	"github.com/odas0r/zet/internal/model"
@@ -24,18 +27,41 @@ const (
 )
 
 func main() {
+	// set by --error-format, before the command runs
+	errorFormat := os.Getenv("ZET_ERROR_FORMAT")
+
 	db := database.NewDatabase(database.NewDatabaseOptions{
 		URL:                databaseUrl,
 		MaxOpenConnections: 1,
 		MaxIdleConnections: 1,
 	})
 	if err := db.Connect(); err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		exitWithError(errorFormat, fmt.Errorf("error: failed to connect to database: %w", err))
 	}
 	config := config.New(rootDir)
-	zr := repository.NewZettelRepository(db, config)
+	// the id format and the promotion rules of <root>/zet.json, the id format
+	// of $ZET_ID_FORMAT
+	if err := config.Load(); err != nil {
+		exitWithError(errorFormat, err)
+	}
+	// set by the editor to group the events of the history
+	config.Session = os.Getenv("ZET_SESSION")
+	zr, err := repository.NewZettelRepository(db, config)
+	if err != nil {
+		exitWithError(errorFormat, usageError(err))
+	}
+
+	app := newApp(zr, &errorFormat)
+
+	if err := app.Run(os.Args); err != nil {
+		// only the errors of parsing the flags get here
+		exitWithError(errorFormat, usageError(err))
+	}
+}
 
-	app := &cli.App{
+// newApp returns the zet commands, errorFormat is set by --error-format
+func newApp(zr repository.ZettelRepository, errorFormat *string) *cli.App {
+	return &cli.App{
 		Name:    "zet",
 		Version: "0.1",
 		Authors: []*cli.Author{
@@ -44,45 +70,83 @@ func main() {
 				Email: "guilherme@muxit.co",
 			},
 		},
-		Usage:                "A zettelkasten under a terminal approach",
-		UsageText:            "A simple way to manage your zettelkasten using neovim (telescope) and fzf",
-		Flags:                []cli.Flag{},
+		Usage:     "A zettelkasten under a terminal approach",
+		UsageText: "A simple way to manage your zettelkasten using neovim (telescope) and fzf",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "format",
+				Usage:   "Output format: json, ndjson, table, tsv, fzf or paths",
+				Value:   FormatJSON,
+				EnvVars: []string{"ZET_FORMAT"},
+			},
+			&cli.StringFlag{
+				Name:  "fields",
+				Usage: "Only these comma separated fields of the output, by their JSON name, like id,title,path",
+			},
+			&cli.StringFlag{
+				Name:  "template",
+				Usage: "A Go template executed for every record of the output, like '{{.Title}}: {{.Path}}'",
+			},
+			&cli.StringFlag{
+				Name:    "error-format",
+				Usage:   "Format of the errors on stderr: text or json, an object with the error, its kind and exit code",
+				Value:   ErrorFormatText,
+				EnvVars: []string{"ZET_ERROR_FORMAT"},
+			},
+		},
+		Before: func(c *cli.Context) error {
+			*errorFormat = c.String("error-format")
+			if *errorFormat != ErrorFormatText && *errorFormat != ErrorFormatJSON {
+				return usageErrorf("error: invalid error format %q, expected text or json", *errorFormat)
+			}
+
+			// fail before running the command
+			_, err := NewOutput(c.String("format"), c.String("fields"), c.String("template"))
+			return err
+		},
+		// exit with the code of the error, see errors.go
+		ExitErrHandler: func(_ *cli.Context, err error) {
+			exitWithError(*errorFormat, err)
+		},
 		EnableBashCompletion: true,
 		Commands: []*cli.Command{
 			{
-				Name:  "new",
-				Usage: "Create a new zettel",
+				Name:      "new",
+				Usage:     "Create a new zettel",
+				ArgsUsage: "<title>",
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
 						Name:  "raw",
 						Usage: "Create a new zettel and output the path to stdout",
 					},
+					&cli.StringFlag{
+						Name:  "parent",
+						Usage: "Branch off the given zettel id (folgezettel id format only)",
+					},
 				},
 				Action: func(c *cli.Context) error {
 					if c.NArg() == 0 {
-						return nil
+						return missingArgs(c)
 					}
 
 					title := strings.Join(c.Args().Slice(), " ")
 
-					zet, err := New(zr, title)
+					zet, err := New(zr, title, c.String("parent"))
 					if err != nil {
-						log.Fatalf("error: failed to create new zettel: %v", err)
+						return fmt.Errorf("error: failed to create new zettel: %w", err)
 					}
 
 					if c.Bool("raw") {
-						bytes, err := json.Marshal(zet)
-						if err != nil {
-							log.Fatalf("error: failed to marshal zettel: %v", err)
+						if err := write(c, zet); err != nil {
+							return fmt.Errorf("error: failed to write zettel: %w", err)
 						}
-						io.WriteString(os.Stdout, string(bytes))
 						return nil
 					}
 
 					yes := fs.InputConfirm("Do you want to open the zettel?")
 					if yes {
 						if err := fs.Editor(zet.Path); err != nil {
-							log.Fatalf("error: failed to open file: %v", err)
+							return fmt.Errorf("error: failed to open file: %w", err)
 						}
 					}
 
@@ -90,11 +154,13 @@ func main() {
 				},
 			},
 			{
-				Name:  "open",
-				Usage: "Opens the zettel by the given path",
+				Name:         "open",
+				Usage:        "Opens the zettel by the given path",
+				ArgsUsage:    "<path>",
+				BashComplete: completeZettels(1, zr.ListAll, byPath),
 				Action: func(c *cli.Context) error {
 					if c.NArg() == 0 {
-						return nil
+						return missingArgs(c)
 					}
 
 					path := c.Args().Slice()[0]
@@ -103,39 +169,41 @@ func main() {
 						Path: path,
 					}
 
-					if !zet.IsValid(config) {
-						log.Fatalf("error: invalid zettel with given path %s", path)
+					if !zet.IsValid(zr.Config()) {
+						return fmt.Errorf("%w: %s", repository.ErrInvalidZettel, path)
+					}
+
+					if _, err := Open(zr, zet.Path); err != nil {
+						return fmt.Errorf("error: failed to record the opened zettel: %w", err)
 					}
 
 					if err := fs.Editor(zet.Path); err != nil {
-						log.Fatalf("error: failed to open file: %v", err)
+						return fmt.Errorf("error: failed to open file: %w", err)
 					}
 
 					return nil
 				},
 			},
 			{
-				Name:  "search",
-				Usage: "Search for zettels using sqlite3 fs5 extension",
+				Name:      "search",
+				Usage:     "Search for zettels using sqlite3 fs5 extension",
+				ArgsUsage: "<query>",
 				Action: func(c *cli.Context) error {
 					if c.NArg() == 0 {
-						return nil
+						return missingArgs(c)
 					}
 
 					query := strings.Join(c.Args().Slice(), " ")
 
 					zettels, err := Search(zr, query)
 					if err != nil {
-						log.Fatalf("error: failed to search for zettels: %v", err)
+						return fmt.Errorf("error: failed to search for zettels: %w", err)
 					}
 
-					bytes, err := json.Marshal(zettels)
-					if err != nil {
-						log.Fatalf("error: failed to marshal zettel: %v", err)
+					if err := write(c, zettels); err != nil {
+						return fmt.Errorf("error: failed to write zettel: %w", err)
 					}
 
-					io.WriteString(os.Stdout, string(bytes))
-
 					return nil
 				},
 			},
@@ -144,60 +212,365 @@ func main() {
 				Aliases: []string{
 					"rm",
 				},
-				Usage: "Removes the given zettel from the database and from the filesystem",
+				Usage:        "Moves the given zettel to the trash",
+				ArgsUsage:    "<path>",
+				BashComplete: completeZettels(1, zr.ListAll, byPath),
 				Action: func(c *cli.Context) error {
 					if c.NArg() == 0 {
-						return nil
+						return missingArgs(c)
 					}
 					path := c.Args().Slice()[0]
 
 					zet, err := Remove(zr, path)
 					if err != nil {
-						log.Fatalf("error: failed to remove zettel: %v", err)
+						return fmt.Errorf("error: failed to remove zettel: %w", err)
 					}
 
-					bytes, err := json.Marshal(zet)
-					if err != nil {
-						log.Fatalf("error: failed to marshal zettel: %v", err)
+					if err := write(c, zet); err != nil {
+						return fmt.Errorf("error: failed to write zettel: %w", err)
 					}
 
-					io.WriteString(os.Stdout, string(bytes))
-
 					return nil
 				},
 			},
+			{
+				Name:  "trash",
+				Usage: "Manages the removed zettels",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "Retrieves all the zettels in the trash",
+						Action: func(c *cli.Context) error {
+							trash, err := TrashList(zr)
+							if err != nil {
+								return fmt.Errorf("error: failed to list the trash: %w", err)
+							}
+
+							if err := write(c, trash); err != nil {
+								return fmt.Errorf("error: failed to write zettel: %w", err)
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:         "restore",
+						Usage:        "Restores the zettel with the given id, and its links",
+						ArgsUsage:    "<id>",
+						BashComplete: completeTrash(zr),
+						Action: func(c *cli.Context) error {
+							if c.NArg() == 0 {
+								return missingArgs(c)
+							}
+							id := c.Args().Slice()[0]
+
+							trash, err := Restore(zr, id)
+							if err != nil {
+								return fmt.Errorf("error: failed to restore zettel: %w", err)
+							}
+
+							if err := write(c, trash); err != nil {
+								return fmt.Errorf("error: failed to write zettel: %w", err)
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:  "empty",
+						Usage: "Permanently removes the zettels in the trash",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "older-than",
+								Usage: "Only remove the zettels trashed before the given duration, like 30d",
+								Value: "0s",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							olderThan, err := parseDuration(c.String("older-than"))
+							if err != nil {
+								return usageError(err)
+							}
+
+							trash, err := EmptyTrash(zr, olderThan)
+							if err != nil {
+								return fmt.Errorf("error: failed to empty the trash: %w", err)
+							}
+
+							if err := write(c, trash); err != nil {
+								return fmt.Errorf("error: failed to write zettel: %w", err)
+							}
+
+							return nil
+						},
+					},
+				},
+			},
 			{
 				Name:  "history",
-				Usage: "Retrieves the last 50 opened zettel",
-				Action: func(_ *cli.Context) error {
-					zettels, err := History(zr)
+				Usage: "Retrieves the events of the zettels (opened, saved, created, promoted, archived, removed), most recent first",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "since",
+						Usage: "Only the events in the given duration, like 7d",
+					},
+					&cli.StringFlag{
+						Name:  "kind",
+						Usage: "Only the events of a kind: opened, saved, created, promoted, archived or removed",
+					},
+					&cli.IntFlag{
+						Name:  "limit",
+						Usage: "Maximum number of results, 0 for no limit",
+						Value: 50,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					filter, err := NewEventFilter(c.String("since"), c.String("kind"), c.Int("limit"))
 					if err != nil {
-						log.Fatalf("error: failed to query the history: %v", err)
+						return usageError(err)
 					}
 
-					bytes, err := json.Marshal(zettels)
+					events, err := History(zr, filter)
 					if err != nil {
-						log.Fatalf("error: failed to marshal zettel: %v", err)
+						return fmt.Errorf("error: failed to query the history: %w", err)
+					}
+
+					if err := write(c, events); err != nil {
+						return fmt.Errorf("error: failed to write event: %w", err)
 					}
-					io.WriteString(os.Stdout, string(bytes))
 
 					return nil
 				},
+				Subcommands: []*cli.Command{
+					{
+						Name:  "recent",
+						Usage: "Retrieves the zettels of the history once each, by their last event",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "since",
+								Usage: "Only the events in the given duration, like 7d",
+							},
+							&cli.StringFlag{
+								Name:  "kind",
+								Usage: "Only the events of a kind: opened, saved, created, promoted, archived or removed",
+							},
+							&cli.IntFlag{
+								Name:  "limit",
+								Usage: "Maximum number of results, 0 for no limit",
+								Value: 50,
+							},
+						},
+						Action: func(c *cli.Context) error {
+							filter, err := NewEventFilter(c.String("since"), c.String("kind"), c.Int("limit"))
+							if err != nil {
+								return usageError(err)
+							}
+
+							zettels, err := Recent(zr, filter)
+							if err != nil {
+								return fmt.Errorf("error: failed to query the history: %w", err)
+							}
+
+							if err := write(c, zettels); err != nil {
+								return fmt.Errorf("error: failed to write zettel: %w", err)
+							}
+
+							return nil
+						},
+					},
+				},
 			},
 			{
 				Name:  "backlog",
-				Usage: "Retrieves all the fleet of zettels",
-				Action: func(_ *cli.Context) error {
-					zettels, err := Backlog(zr)
+				Usage: "Retrieves all the fleet of zettels, with their age and links",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "sort",
+						Usage: "Orders the zettels by updated (newest first), age (oldest first), stale (least recently updated first) or links (fewest first)",
+						Value: SortUpdated,
+					},
+					&cli.StringFlag{
+						Name:  "older-than",
+						Usage: "Flags the zettels older than the given duration as stale, like 30d",
+						Value: "30d",
+					},
+					&cli.BoolFlag{
+						Name:  "stale",
+						Usage: "Only the stale zettels",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					filter, err := backlogFilter(c)
+					if err != nil {
+						return usageError(err)
+					}
+
+					items, err := Backlog(zr, filter)
+					if err != nil {
+						return fmt.Errorf("error: failed to query the backlog: %w", err)
+					}
+
+					if err := write(c, items); err != nil {
+						return fmt.Errorf("error: failed to write zettel: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "triage",
+				Usage: "Walks the backlog, oldest first, asking to promote, archive, merge, delete, edit or skip each zettel",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "sort",
+						Usage: "Orders the zettels by updated (newest first), age (oldest first), stale (least recently updated first) or links (fewest first)",
+						Value: SortAge,
+					},
+					&cli.StringFlag{
+						Name:  "older-than",
+						Usage: "Flags the zettels older than the given duration as stale, like 30d",
+						Value: "30d",
+					},
+					&cli.BoolFlag{
+						Name:  "stale",
+						Usage: "Only the stale zettels",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					filter, err := backlogFilter(c)
+					if err != nil {
+						return usageError(err)
+					}
+
+					items, err := Backlog(zr, filter)
+					if err != nil {
+						return fmt.Errorf("error: failed to query the backlog: %w", err)
+					}
+
+					// the prompts go to stderr, so the actions can be piped
+					actions, err := Triage(zr, items, os.Stdin, os.Stderr, fs.Editor)
+					if err != nil {
+						return fmt.Errorf("error: failed to triage the backlog: %w", err)
+					}
+
+					if err := write(c, actions); err != nil {
+						return fmt.Errorf("error: failed to write actions: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:         "archive",
+				Usage:        "Puts the given fleet zettel aside in the archive, out of the backlog",
+				ArgsUsage:    "<path>",
+				BashComplete: completeZettels(1, zr.ListFleet, byPath),
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return missingArgs(c)
+					}
+					path := c.Args().Slice()[0]
+
+					zet, err := Archive(zr, path)
 					if err != nil {
-						log.Fatalf("error: failed to query the backlog: %v", err)
+						return fmt.Errorf("error: failed to archive zettel: %w", err)
+					}
+
+					if err := write(c, zet); err != nil {
+						return fmt.Errorf("error: failed to write zettel: %w", err)
 					}
 
-					bytes, err := json.Marshal(zettels)
+					return nil
+				},
+			},
+			{
+				Name:         "merge",
+				Usage:        "Merges the source zettel into the destination: appends its body under a heading, points its backlinks at the destination and moves it to the trash",
+				ArgsUsage:    "<src> <dst>",
+				BashComplete: completeZettels(2, zr.ListAll, bySlug),
+				Action: func(c *cli.Context) error {
+					if c.NArg() < 2 {
+						return missingArgs(c)
+					}
+
+					zet, err := Merge(zr, c.Args().Get(0), c.Args().Get(1))
 					if err != nil {
-						log.Fatalf("error: failed to marshal zettel: %v", err)
+						return fmt.Errorf("error: failed to merge zettels: %w", err)
+					}
+
+					if err := write(c, zet); err != nil {
+						return fmt.Errorf("error: failed to write zettel: %w", err)
 					}
-					io.WriteString(os.Stdout, string(bytes))
+
+					return nil
+				},
+			},
+			{
+				Name:         "split",
+				Usage:        "Splits the given zettel into new zettels, one per ## section or range of lines, linked from where they were",
+				ArgsUsage:    "<zettel>",
+				BashComplete: completeZettels(1, zr.ListAll, bySlug),
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "by-heading",
+						Usage: "A new zettel per ## section, titled by its heading, the default",
+					},
+					&cli.StringFlag{
+						Name:  "lines",
+						Usage: "A new zettel per range of lines, like 3-10,12-20, titled by its first line",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return missingArgs(c)
+					}
+					if c.Bool("by-heading") && c.IsSet("lines") {
+						return usageErrorf("error: --by-heading and --lines cannot be used together")
+					}
+
+					var ranges []*LineRange
+					if c.IsSet("lines") {
+						parsed, err := ParseLineRanges(c.String("lines"))
+						if err != nil {
+							return usageError(err)
+						}
+						ranges = parsed
+					}
+
+					zettels, err := Split(zr, c.Args().First(), ranges)
+					if err != nil {
+						return fmt.Errorf("error: failed to split zettel: %w", err)
+					}
+
+					if err := write(c, zettels); err != nil {
+						return fmt.Errorf("error: failed to write zettels: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:         "render",
+				Usage:        "Prints the given zettel as markdown, with its ![[embeds]] expanded",
+				ArgsUsage:    "<zettel>",
+				BashComplete: completeZettels(1, zr.ListAll, bySlug),
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "depth",
+						Usage: "How deep embeds inside embeds are expanded",
+						Value: MaxEmbedDepth,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return missingArgs(c)
+					}
+
+					content, err := Render(zr, c.Args().First(), c.Int("depth"))
+					if err != nil {
+						return fmt.Errorf("error: failed to render zettel: %w", err)
+					}
+					io.WriteString(os.Stdout, strings.TrimRight(content, "\n")+"\n")
 
 					return nil
 				},
@@ -205,63 +578,89 @@ func main() {
 			{
 				Name:  "brokenlinks",
 				Usage: "Retrieves all the brokenlinks of a zettel",
-				Action: func(_ *cli.Context) error {
+				Action: func(c *cli.Context) error {
 					zettels, err := BrokenLinks(zr)
 					if err != nil {
-						log.Fatalf("error: failed to query all the brokenlinks of a zettel: %v", err)
+						return fmt.Errorf("error: failed to query all the brokenlinks of a zettel: %w", err)
 					}
 
-					bytes, err := json.Marshal(zettels)
-					if err != nil {
-						log.Fatalf("error: failed to marshal zettel: %v", err)
+					if err := write(c, zettels); err != nil {
+						return fmt.Errorf("error: failed to write zettel: %w", err)
 					}
-					io.WriteString(os.Stdout, string(bytes))
 
 					return nil
 				},
 			},
 			{
-				Name:  "permanent",
-				Usage: "Sets the given zettel as type permanent",
+				Name:         "permanent",
+				Usage:        "Sets the given zettel as type permanent, once it follows the promotion rules",
+				ArgsUsage:    "<path>",
+				BashComplete: completeZettels(1, zr.ListFleet, byPath),
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Skips the promotion rules",
+					},
+				},
 				Action: func(c *cli.Context) error {
 					if c.NArg() == 0 {
-						return nil
+						return missingArgs(c)
 					}
 					path := c.Args().Slice()[0]
 
-					zet, err := Permanent(zr, path)
+					zet, err := Permanent(zr, path, c.Bool("force"))
 					if err != nil {
-						log.Fatalf("error: failed to set zettel as permanent: %v", err)
+						return fmt.Errorf("error: failed to set zettel as permanent: %w", err)
+					}
+
+					if err := write(c, zet); err != nil {
+						return fmt.Errorf("error: failed to write zettel: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:         "lint",
+				Usage:        "Lists the promotion rules the given zettel breaks, an empty list when it is ready to be permanent",
+				ArgsUsage:    "<zettel>",
+				BashComplete: completeZettels(1, zr.ListAll, bySlug),
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return missingArgs(c)
 					}
 
-					bytes, err := json.Marshal(zet)
+					violations, err := Lint(zr, c.Args().First())
 					if err != nil {
-						log.Fatalf("error: failed to marshal zettel: %v", err)
+						return fmt.Errorf("error: failed to lint zettel: %w", err)
+					}
+
+					if err := write(c, violations); err != nil {
+						return fmt.Errorf("error: failed to write violations: %w", err)
 					}
-					io.WriteString(os.Stdout, string(bytes))
 
 					return nil
 				},
 			},
 			{
-				Name:  "fleet",
-				Usage: "Sets the given zettel as type fleet",
+				Name:         "fleet",
+				Usage:        "Sets the given zettel as type fleet",
+				ArgsUsage:    "<path>",
+				BashComplete: completeZettels(1, zr.ListPermanent, byPath),
 				Action: func(c *cli.Context) error {
 					if c.NArg() == 0 {
-						return nil
+						return missingArgs(c)
 					}
 					path := c.Args().Slice()[0]
 
 					zet, err := Fleet(zr, path)
 					if err != nil {
-						log.Fatalf("error: failed to set zettel as permanent: %v", err)
+						return fmt.Errorf("error: failed to set zettel as permanent: %w", err)
 					}
 
-					bytes, err := json.Marshal(zet)
-					if err != nil {
-						log.Fatalf("error: failed to marshal zettel: %v", err)
+					if err := write(c, zet); err != nil {
+						return fmt.Errorf("error: failed to write zettel: %w", err)
 					}
-					io.WriteString(os.Stdout, string(bytes))
 
 					return nil
 				},
@@ -269,43 +668,487 @@ func main() {
 			{
 				Name:  "last",
 				Usage: "Retrieves the last opened zettel",
-				Action: func(_ *cli.Context) error {
+				Action: func(c *cli.Context) error {
 					// fetch the last edited zettel
 					zet, err := Last(zr)
 					if err != nil {
-						log.Fatalf("error: failed to query the last opened zettel: %v", err)
+						return fmt.Errorf("error: failed to query the last opened zettel: %w", err)
 					}
 
-					bytes, err := json.Marshal(zet)
-					if err != nil {
-						log.Fatalf("error: failed to marshal zettel: %v", err)
+					if err := write(c, zet); err != nil {
+						return fmt.Errorf("error: failed to write zettel: %w", err)
 					}
 
-					io.WriteString(os.Stdout, string(bytes))
-
 					return nil
 				},
 			},
 			{
-				Name:  "save",
-				Usage: "Inserts or updates the given zettel to the database, and some repairs",
+				Name:         "save",
+				Usage:        "Inserts or updates the given zettel to the database, and some repairs",
+				ArgsUsage:    "<path>",
+				BashComplete: completeZettels(1, zr.ListAll, byPath),
 				Action: func(c *cli.Context) error {
 					if c.NArg() == 0 {
-						return nil
+						return missingArgs(c)
 					}
 					path := c.Args().Slice()[0]
 
 					zet, err := Save(zr, path)
 					if err != nil {
-						log.Fatalf("error: failed to save zettel: %v", err)
+						return fmt.Errorf("error: failed to save zettel: %w", err)
+					}
+
+					if err := write(c, zet); err != nil {
+						return fmt.Errorf("error: failed to write zettel: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "review",
+				Usage: "Retrieves the next permanent zettel due for review, null when nothing is due",
+				Action: func(c *cli.Context) error {
+					due, err := NextReview(zr)
+					if err != nil {
+						return fmt.Errorf("error: failed to query the review queue: %w", err)
+					}
+
+					if err := write(c, due); err != nil {
+						return fmt.Errorf("error: failed to write review: %w", err)
+					}
+
+					return nil
+				},
+				Subcommands: []*cli.Command{
+					{
+						Name:      "rate",
+						Usage:     "Rates how well the zettel was recalled, from 1 (forgotten) to 5 (perfect), and schedules its next review",
+						ArgsUsage: "<zettel> <1-5>",
+						BashComplete: func(c *cli.Context) {
+							if c.NArg() == 1 {
+								completeValues(1, "1", "2", "3", "4", "5")(c)
+								return
+							}
+							completeZettels(1, zr.ListPermanent, bySlug)(c)
+						},
+						Action: func(c *cli.Context) error {
+							if c.NArg() < 2 {
+								return missingArgs(c)
+							}
+
+							rating, err := strconv.Atoi(c.Args().Get(1))
+							if err != nil {
+								return usageErrorf("error: invalid rating %s: %v", c.Args().Get(1), err)
+							}
+
+							review, err := RateReview(zr, c.Args().Get(0), rating)
+							if err != nil {
+								return fmt.Errorf("error: failed to rate the review: %w", err)
+							}
+
+							if err := write(c, review); err != nil {
+								return fmt.Errorf("error: failed to write review: %w", err)
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:  "due",
+						Usage: "Retrieves the number of zettels due for review, for the editor statusline",
+						Action: func(c *cli.Context) error {
+							stats, err := ReviewStats(zr)
+							if err != nil {
+								return fmt.Errorf("error: failed to count the reviews: %w", err)
+							}
+
+							if err := write(c, stats); err != nil {
+								return fmt.Errorf("error: failed to write review: %w", err)
+							}
+
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "stats",
+				Usage: "Shows the writing activity: zettels created and promoted per period, words, conversion to permanent, streaks and a heatmap",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "period",
+						Usage: "Groups the activity by day, week or month",
+						Value: PeriodWeek,
+					},
+					&cli.StringFlag{
+						Name:  "since",
+						Usage: "Only the periods in the given duration, like 12w",
+						Value: "26w",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Outputs the statistics as JSON, for dashboards",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					since, err := parseDuration(c.String("since"))
+					if err != nil {
+						return usageError(err)
+					}
+
+					stats, err := Stats(zr, c.String("period"), since)
+					if err != nil {
+						return fmt.Errorf("error: failed to compute the statistics: %w", err)
+					}
+
+					if !c.Bool("json") && !c.IsSet("format") && !c.IsSet("template") {
+						WriteStats(os.Stdout, stats)
+						return nil
+					}
+
+					if err := write(c, stats); err != nil {
+						return fmt.Errorf("error: failed to write statistics: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "doctor",
+				Usage: "Checks that the filesystem and the database agree with each other",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "fix",
+						Usage: "Apply the safe repairs (missing files, index drift, orphaned history)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					issues, err := Doctor(zr, c.Bool("fix"))
+					if err != nil {
+						return fmt.Errorf("error: failed to run the doctor: %w", err)
+					}
+
+					if err := write(c, issues); err != nil {
+						return fmt.Errorf("error: failed to write issues: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "serve",
+				Usage: "Serves the zettels over a JSON HTTP API",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "addr",
+						Usage: "Address to listen on, the API has no authentication so only this machine by default",
+						Value: "127.0.0.1:7777",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+					defer stop()
+
+					log.Printf("Listening on %s\n", c.String("addr"))
+
+					if err := Serve(ctx, zr, c.String("addr")); err != nil {
+						return fmt.Errorf("error: failed to serve: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "lsp",
+				Usage: "Starts a language server for the zettels over stdio",
+				Action: func(c *cli.Context) error {
+					if err := LSP(c.Context, zr, os.Stdin, os.Stdout); err != nil {
+						return fmt.Errorf("error: language server failed: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "rpc",
+				Usage: "Serves newline delimited JSON-RPC 2.0 requests over stdio, for editor plugins",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "watch",
+						Usage: "Send a zettel/changed notification when a zettel file changes",
+						Value: true,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := RPC(c.Context, zr, os.Stdin, os.Stdout, c.Bool("watch")); err != nil {
+						return fmt.Errorf("error: rpc failed: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "git",
+				Usage: "Versions the zettelkasten with git",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "sync",
+						Usage: "Commits the changed zettels, pulls, syncs the index and pushes",
+						Action: func(c *cli.Context) error {
+							report, syncErr := GitSync(zr)
+							if report != nil {
+								if err := write(c, report); err != nil {
+									return fmt.Errorf("error: failed to write report: %w", err)
+								}
+							}
+
+							if syncErr != nil {
+								return fmt.Errorf("error: failed to sync with git: %w", syncErr)
+							}
+
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:         "log",
+				Usage:        "Retrieves the commits that changed the given zettel, following its moves",
+				ArgsUsage:    "<zettel>",
+				BashComplete: completeZettels(1, zr.ListAll, bySlug),
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return missingArgs(c)
+					}
+
+					commits, err := GitLog(zr, c.Args().First())
+					if err != nil {
+						return fmt.Errorf("error: failed to get the log: %w", err)
+					}
+
+					if err := write(c, commits); err != nil {
+						return fmt.Errorf("error: failed to write commits: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:         "diff",
+				Usage:        "Shows the changes of the given zettel since a revision, HEAD by default",
+				ArgsUsage:    "<zettel> [rev]",
+				BashComplete: completeZettels(1, zr.ListAll, bySlug),
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return missingArgs(c)
 					}
 
-					bytes, err := json.Marshal(zet)
+					diff, err := GitDiff(zr, c.Args().Get(0), c.Args().Get(1))
 					if err != nil {
-						log.Fatalf("error: failed to marshal zettel: %v", err)
+						return fmt.Errorf("error: failed to get the diff: %w", err)
 					}
 
-					io.WriteString(os.Stdout, string(bytes))
+					io.WriteString(os.Stdout, diff)
+
+					return nil
+				},
+			},
+			{
+				Name:         "show",
+				Usage:        "Prints the given zettel as it was at a revision",
+				ArgsUsage:    "<zettel>@<rev>",
+				BashComplete: completeZettels(1, zr.ListAll, bySlug),
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return missingArgs(c)
+					}
+
+					content, err := GitShow(zr, c.Args().First())
+					if err != nil {
+						return fmt.Errorf("error: failed to show the zettel: %w", err)
+					}
+
+					io.WriteString(os.Stdout, content)
+
+					return nil
+				},
+			},
+			{
+				Name:  "dump",
+				Usage: "Writes every zettel with its content, links, history, reviews and tags as NDJSON to stdout",
+				Action: func(_ *cli.Context) error {
+					w := bufio.NewWriter(os.Stdout)
+
+					if _, err := Dump(zr, w); err != nil {
+						return fmt.Errorf("error: failed to dump zettels: %w", err)
+					}
+
+					if err := w.Flush(); err != nil {
+						return fmt.Errorf("error: failed to write dump: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:      "restore",
+				Usage:     "Rebuilds the files and the database from a dump, - reads from stdin",
+				ArgsUsage: "<file>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return missingArgs(c)
+					}
+
+					r := io.Reader(os.Stdin)
+					if path := c.Args().First(); path != "-" {
+						f, err := os.Open(path)
+						if err != nil {
+							return fmt.Errorf("error: failed to open dump: %w", err)
+						}
+						defer f.Close()
+						r = f
+					}
+
+					zettels, err := RestoreDump(zr, r)
+					if err != nil {
+						return fmt.Errorf("error: failed to restore dump: %w", err)
+					}
+
+					if err := write(c, zettels); err != nil {
+						return fmt.Errorf("error: failed to write zettels: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "import",
+				Usage: "Imports zettels from other tools",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "obsidian",
+						Usage:     "Imports the notes of an Obsidian vault",
+						ArgsUsage: "<vault-dir>",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "map",
+								Usage: "Maps a folder of the vault to a zettel type, like --map Notes=permanent",
+							},
+							&cli.StringFlag{
+								Name:  "type",
+								Usage: "Type of the notes outside of the mapped folders",
+								Value: "fleet",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							vault := c.Args().First()
+							if vault == "" {
+								return missingArgs(c)
+							}
+							if !fs.IsDir(vault) {
+								return usageErrorf("error: %s is not a directory", vault)
+							}
+
+							if c.String("type") != "fleet" && c.String("type") != "permanent" {
+								return usageErrorf("error: type must be fleet or permanent")
+							}
+
+							folders := make(map[string]string)
+							for _, m := range c.StringSlice("map") {
+								folder, typ, ok := strings.Cut(m, "=")
+								if !ok || folder == "" || (typ != "fleet" && typ != "permanent") {
+									return usageErrorf("error: invalid mapping %q, expected <folder>=fleet|permanent", m)
+								}
+								folders[folder] = typ
+							}
+
+							report, err := ImportObsidian(zr, vault, folders, c.String("type"))
+							if err != nil {
+								return fmt.Errorf("error: failed to import the vault: %w", err)
+							}
+
+							if err := write(c, report); err != nil {
+								return fmt.Errorf("error: failed to write report: %w", err)
+							}
+
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "export",
+				Usage: "Exports the zettels to other formats",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "html",
+						Usage: "Renders the permanent zettels as a static html site",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "out",
+								Usage: "Directory to write the site to",
+								Value: "./site",
+							},
+							&cli.BoolFlag{
+								Name:  "fleet",
+								Usage: "Export the fleet zettels too",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							report, err := ExportHTML(zr, c.String("out"), c.Bool("fleet"))
+							if err != nil {
+								return fmt.Errorf("error: failed to export zettels: %w", err)
+							}
+
+							if err := write(c, report); err != nil {
+								return fmt.Errorf("error: failed to write report: %w", err)
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:  "markdown",
+						Usage: "Writes the zettels as plain markdown notes, named after their titles, with a YAML front matter",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "out",
+								Usage: "Directory to write the notes to",
+								Value: "./vault",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							report, err := ExportMarkdown(zr, c.String("out"))
+							if err != nil {
+								return fmt.Errorf("error: failed to export zettels: %w", err)
+							}
+
+							if err := write(c, report); err != nil {
+								return fmt.Errorf("error: failed to write report: %w", err)
+							}
+
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:         "completion",
+				Usage:        "Prints the completion script of the shell, like source <(zet completion bash)",
+				ArgsUsage:    "<bash|zsh|fish>",
+				BashComplete: completeValues(0, shells...),
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return missingArgs(c)
+					}
+
+					script, err := Completion(c.Args().First())
+					if err != nil {
+						return err
+					}
+
+					io.WriteString(os.Stdout, script)
 
 					return nil
 				},
@@ -314,20 +1157,40 @@ func main() {
 				// indexing phase
 				Name:  "sync",
 				Usage: "Sync the filesystem with the database and does some fixing on the side",
-				Action: func(_ *cli.Context) error {
-					if err := Sync(zr); err != nil {
-						log.Fatalf("error: failed to sync zettels: %v", err)
+				Action: func(c *cli.Context) error {
+					report, err := Sync(zr)
+					if err != nil {
+						return fmt.Errorf("error: failed to sync zettels: %w", err)
 					}
 
-					fmt.Println("Synced! :)")
+					if err := write(c, report); err != nil {
+						return fmt.Errorf("error: failed to write report: %w", err)
+					}
 
 					return nil
 				},
 			},
 		},
 	}
+}
 
-	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
+// backlogFilter returns the filter of the backlog and triage flags
+func backlogFilter(c *cli.Context) (*BacklogFilter, error) {
+	olderThan, err := parseDuration(c.String("older-than"))
+	if err != nil {
+		return nil, err
 	}
+
+	return &BacklogFilter{Sort: c.String("sort"), OlderThan: olderThan, Stale: c.Bool("stale")}, nil
+}
+
+// write writes the result of a command to stdout, in the format of the global
+// flags
+func write(c *cli.Context, v any) error {
+	out, err := NewOutput(c.String("format"), c.String("fields"), c.String("template"))
+	if err != nil {
+		return err
+	}
+
+	return out.Write(os.Stdout, v)
 }
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+// a line with nothing but #tags, like the last line of most zettels
+var tagLineRe = regexp.MustCompile(`^#[a-zA-Z][\w/-]*(\s+#[a-zA-Z][\w/-]*)*$`)
+
+// Merge merges the source zettel into the destination, both given by their
+// path, id, slug or title, see MergeInto
+func Merge(zr repository.ZettelRepository, srcArg string, dstArg string) (*model.Zettel, error) {
+	src, err := findZettel(zr, srcArg)
+	if err != nil {
+		return nil, err
+	}
+
+	dst, err := findZettel(zr, dstArg)
+	if err != nil {
+		return nil, err
+	}
+
+	return MergeInto(zr, src, dst)
+}
+
+// MergeInto appends the body of the source zettel to the destination, under
+// a heading with the source title, with the tags the destination lacks. The
+// links to the source are pointed at the destination, the source slug becomes
+// an alias of the destination and the source is moved to the trash. The
+// index is updated in one transaction, the files are restored if it fails.
+func MergeInto(zr repository.ZettelRepository, src *model.Zettel, dst *model.Zettel) (*model.Zettel, error) {
+	ctx := context.Background()
+	cfg := zr.Config()
+
+	if src.ID == dst.ID {
+		return nil, fmt.Errorf("error: cannot merge %s into itself", src.Slug)
+	}
+
+	content, err := fs.Read(src.Path)
+	if err != nil {
+		return nil, err
+	}
+	_, body, _ := strings.Cut(content, "\n")
+
+	dstContent, err := fs.Read(dst.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	body, tags := cutTagLines(body)
+
+	// tags already in the destination, or still in the body, are not repeated
+	have := (&model.Zettel{Content: dstContent + "\n" + body}).Tags()
+	var missing []string
+	for _, tag := range tags {
+		if !contains(have, tag) && !contains(missing, "#"+tag) {
+			missing = append(missing, "#"+tag)
+		}
+	}
+
+	merged := strings.TrimRight(dstContent, "\n") + "\n\n## " + src.Title + "\n\n" + strings.TrimSpace(body) + "\n"
+	if len(missing) > 0 {
+		merged += "\n" + strings.Join(missing, " ") + "\n"
+	}
+
+	// path -> content, of the files to write and to restore on failure
+	contents := map[string]string{dst.Path: relink(ctx, zr, dst.Path, merged, src, dst)}
+	originals := map[string]string{dst.Path: dstContent}
+	// the id of a permanent zettel is not its file name
+	ids := map[string]string{dst.Path: dst.ID}
+	paths := []string{dst.Path}
+
+	backlinks, err := zr.Backlinks(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, zet := range backlinks {
+		if zet.ID == dst.ID || zet.ID == src.ID {
+			continue
+		}
+
+		content, err := fs.Read(zet.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if rewritten := relink(ctx, zr, zet.Path, content, src, dst); rewritten != content {
+			contents[zet.Path] = rewritten
+			originals[zet.Path] = content
+			ids[zet.Path] = zet.ID
+			paths = append(paths, zet.Path)
+		}
+	}
+
+	// restore puts the files back, the errors of doing so are added to err
+	restore := func(err error) error {
+		for path, content := range originals {
+			err = withUndo(err, fs.WriteAtomic(path, content))
+		}
+		return err
+	}
+
+	zettels := make([]*model.Zettel, len(paths))
+	for i, path := range paths {
+		if err := fs.WriteAtomic(path, contents[path]); err != nil {
+			return nil, restore(err)
+		}
+
+		zet := &model.Zettel{Path: path}
+		if err := zet.Read(cfg); err != nil {
+			return nil, restore(err)
+		}
+		zet.ID = ids[path]
+
+		for _, link := range zet.Links {
+			if err := zr.Resolve(ctx, link); err != nil && !isUnresolved(err) {
+				return nil, restore(err)
+			}
+		}
+
+		zettels[i] = zet
+	}
+
+	// Move the source to the trash before the merge is committed, it fails if
+	// there's already a file there, see zr.Trash for the path
+	trashPath := cfg.TrashRoot + "/" + src.ID + ".md"
+	moved := fs.Exists(src.Path)
+	if moved {
+		if err := fs.Move(src.Path, trashPath); err != nil {
+			return nil, restore(err)
+		}
+	}
+
+	if _, err := zr.Merge(ctx, src, zettels[0], zettels[1:]); err != nil {
+		err = restore(err)
+		if moved {
+			err = withUndo(err, fs.Move(trashPath, src.Path))
+		}
+		return nil, err
+	}
+
+	if err := zr.InsertEvent(ctx, src, model.EventRemoved); err != nil {
+		return nil, err
+	}
+	for _, zet := range zettels {
+		if err := zr.InsertEvent(ctx, zet, model.EventSaved); err != nil {
+			return nil, err
+		}
+	}
+
+	return zettels[0], nil
+}
+
+// relink points the [[links]] to the source in the content at the
+// destination. In the destination itself they become plain text, as do its
+// links to itself.
+func relink(ctx context.Context, zr repository.ZettelRepository, path string, content string, src *model.Zettel, dst *model.Zettel) string {
+	lines := strings.Split(content, "\n")
+	rewriteLinks(ctx, zr, lines, func(text string, target *model.Zettel) string {
+		if target == nil {
+			return "[[" + text + "]]"
+		}
+		if path == dst.Path && (target.ID == src.ID || target.ID == dst.ID) {
+			return target.Title
+		}
+		if target.ID != src.ID {
+			return "[[" + text + "]]"
+		}
+		if _, heading, ok := strings.Cut(text, "#"); ok {
+			return "[[" + dst.Slug + "#" + heading + "]]"
+		}
+		return "[[" + dst.Slug + "]]"
+	})
+
+	return strings.Join(lines, "\n")
+}
+
+// cutTagLines removes the lines with nothing but #tags from the body, outside
+// of fenced code blocks, and returns their tags
+func cutTagLines(body string) (string, []string) {
+	var kept []string
+	var tags []string
+
+	fenced := false
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			fenced = !fenced
+		}
+		if fenced || !tagLineRe.MatchString(trimmed) {
+			kept = append(kept, line)
+			continue
+		}
+
+		tags = append(tags, (&model.Zettel{Content: trimmed}).Tags()...)
+	}
+
+	return strings.Join(kept, "\n"), tags
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+func TestMerge(t *testing.T) {
+	t.Run("merge -> relink backlinks -> union tags -> alias", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+		ctx := context.Background()
+
+		z3 := createZet(t, zr, "A title three")
+
+		z1 := createZet(t, zr, "A title one")
+		z1.WriteLine(fmt.Sprintf("A source line, see [[%s]]\n\n#idea #shared", z3.Slug))
+		saveZet(t, zr, z1)
+
+		z2 := createZet(t, zr, "A title two")
+		z2.WriteLine("A destination line\n\n#shared")
+		saveZet(t, zr, z2)
+
+		z3.WriteLine(fmt.Sprintf("Links [[%s]] and [[%s#Intro]]", z1.Slug, z1.Slug))
+		saveZet(t, zr, z3)
+
+		_, err := Merge(zr, z1.Slug, z1.ID)
+		assert.NotEqual(t, err, nil, "a zettel should not be merged into itself")
+
+		merged, err := Merge(zr, z1.Slug, z2.ID)
+		require.Equal(t, err, nil, "failed to merge z1 into z2")
+		assert.Equal(t, merged.ID, z2.ID, "z2 should be returned")
+		assert.Equal(t, strings.Contains(merged.Content, "## A title one\n\nA source line"), true, "z1 body should be under its heading")
+		assert.Equal(t, strings.Count(merged.Content, "#shared"), 1, "shared tags should not be repeated")
+		assert.Equal(t, strings.Contains(merged.Content, "#idea"), true, "z1 tags should be added")
+
+		dst := &model.Zettel{ID: z2.ID}
+		err = zr.Get(ctx, dst)
+		require.Equal(t, err, nil, "failed to get z2")
+		assert.Equal(t, dst.Content, merged.Content, "the index should have the merged content")
+		require.Equal(t, len(dst.Links), 1, "z2 should have the links of z1")
+		assert.Equal(t, dst.Links[0].ID, z3.ID, "z2 should link to z3")
+
+		backlink := &model.Zettel{ID: z3.ID}
+		err = zr.Get(ctx, backlink)
+		require.Equal(t, err, nil, "failed to get z3")
+		assert.Equal(t, strings.Contains(backlink.Content, "[["+z2.Slug+"]] and [["+z2.Slug+"#Intro]]"), true, "z3 should link to z2, keeping the heading")
+		require.Equal(t, len(backlink.Links), 1, "z3 should have one link")
+		assert.Equal(t, backlink.Links[0].ID, z2.ID, "z3 link should be indexed")
+
+		err = zr.Get(ctx, &model.Zettel{ID: z1.ID})
+		assert.NotEqual(t, err, nil, "z1 should be removed")
+
+		trash, err := TrashList(zr)
+		require.Equal(t, err, nil, "failed to list the trash")
+		require.Equal(t, len(trash), 1, "z1 should be in the trash")
+		assert.Equal(t, trash[0].ID, z1.ID, "z1 should be in the trash")
+
+		aliases, err := zr.Aliases(ctx, z2)
+		require.Equal(t, err, nil, "failed to get the aliases")
+		assert.Equal(t, strings.Join(aliases, ","), z1.Slug, "z1 slug should be an alias of z2")
+
+		link := model.NewLink(z1.Slug)
+		err = zr.Resolve(ctx, link)
+		require.Equal(t, err, nil, "the alias should resolve")
+		assert.Equal(t, link.ID, z2.ID, "the alias should resolve to z2")
+
+		// the aliases follow the zettel when it is merged again
+		z4 := createZet(t, zr, "A title four")
+		_, err = Merge(zr, z2.Slug, z4.Slug)
+		require.Equal(t, err, nil, "failed to merge z2 into z4")
+
+		aliases, err = zr.Aliases(ctx, z4)
+		require.Equal(t, err, nil, "failed to get the aliases")
+		assert.Equal(t, len(aliases), 2, "z1 and z2 slugs should be aliases of z4")
+
+		found, err := findZettel(zr, z1.Slug)
+		require.Equal(t, err, nil, "the alias should be found")
+		assert.Equal(t, found.ID, z4.ID, "the alias should point at z4")
+	})
+	t.Run("failed merges leave the files and the database in place", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, cfg := startup(t)
+		ctx := context.Background()
+
+		z1 := createZet(t, zr, "A title one")
+		z2 := createZet(t, zr, "A title two")
+		z3 := createZet(t, zr, "A title three")
+		z3.WriteLine(fmt.Sprintf("Links [[%s]]", z1.Slug))
+		saveZet(t, zr, z3)
+
+		before := map[string]string{}
+		for _, zet := range []*model.Zettel{z1, z2, z3} {
+			content, err := fs.Read(zet.Path)
+			require.Equal(t, err, nil, "failed to read "+zet.Slug)
+			before[zet.Path] = content
+		}
+
+		// a file left in the trash
+		err := fs.Write(cfg.TrashRoot+"/"+z1.ID+".md", "# A stale title\n")
+		require.Equal(t, err, nil, "failed to write the stale file")
+
+		_, err = Merge(zr, z1.Slug, z2.ID)
+		assert.Equal(t, errors.Is(err, os.ErrExist), true, "the stale file should not be overwritten")
+
+		for path, content := range before {
+			after, err := fs.Read(path)
+			require.Equal(t, err, nil, "failed to read "+path)
+			assert.Equal(t, after, content, "the files should be restored: "+path)
+		}
+
+		err = zr.Get(ctx, &model.Zettel{ID: z1.ID})
+		assert.Equal(t, err, nil, "z1 should stay in the database")
+	})
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+type UnresolvedLink struct {
+	Path string `json:"path"`
+	Link string `json:"link"`
+}
+
+type ImportReport struct {
+	Zettels     []*model.Zettel   `json:"zettels"`
+	Attachments []string          `json:"attachments"`
+	Unresolved  []*UnresolvedLink `json:"unresolved"`
+	// links to notes whose display text was dropped, zet links have none
+	Undisplayed []*UnresolvedLink `json:"undisplayed"`
+}
+
+// obsidianNote is a note of the vault, before it is written as a zettel
+type obsidianNote struct {
+	// path relative to the vault
+	path    string
+	title   string
+	aliases []string
+	tags    []string
+	body    string
+	zet     *model.Zettel
+}
+
+// ImportObsidian imports the notes of an Obsidian vault as zettels:
+//
+// - every note gets a new id, its title is the "title" field of the front
+// matter or the filename
+// - the "tags" of the front matter are appended as #tags, the "aliases" are
+// used to resolve the links and are kept as aliases of the zettel
+// - [[Note Name#Heading]] and ![[Note Name#Heading]] are converted to slug
+// links, links to attachments to markdown links to the copied attachment. The
+// display text of [[Note Name|display]] is dropped and reported.
+// - the other files of the vault are copied to the attachments directory
+// - a note is permanent or fleet based on the longest folder of the vault it
+// is in, the notes outside of folders get defaultType
+//
+// Everything is indexed in one pass at the end, the links that could not be
+// resolved are reported.
+func ImportObsidian(zr repository.ZettelRepository, vault string, folders map[string]string, defaultType string) (*ImportReport, error) {
+	ctx := context.Background()
+	cfg := zr.Config()
+
+	report := &ImportReport{
+		Zettels:     []*model.Zettel{},
+		Attachments: []string{},
+		Unresolved:  []*UnresolvedLink{},
+		Undisplayed: []*UnresolvedLink{},
+	}
+
+	var notes []*obsidianNote
+	var attachments []string
+
+	err := filepath.WalkDir(vault, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// skip .obsidian, .trash and the other hidden files
+		if path != vault && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(vault, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if filepath.Ext(path) != ".md" {
+			attachments = append(attachments, rel)
+			return nil
+		}
+
+		content, err := fs.Read(path)
+		if err != nil {
+			return err
+		}
+
+		notes = append(notes, parseObsidianNote(rel, content))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// fail before writing anything, instead of overwriting the attachments of
+	// a previous import
+	for _, rel := range attachments {
+		dst := filepath.Join(cfg.AttachmentsRoot, filepath.FromSlash(rel))
+		if fs.Exists(dst) {
+			return nil, fmt.Errorf("error: attachment %s already exists: %w", dst, os.ErrExist)
+		}
+	}
+
+	// the files created so far are removed if the import fails
+	var created []string
+	defer func() {
+		if err != nil {
+			for _, path := range created {
+				fs.Remove(path)
+			}
+		}
+	}()
+
+	for _, rel := range attachments {
+		dst := filepath.Join(cfg.AttachmentsRoot, filepath.FromSlash(rel))
+		if err = fs.Copy(filepath.Join(vault, filepath.FromSlash(rel)), dst); err != nil {
+			return nil, err
+		}
+		created = append(created, dst)
+		report.Attachments = append(report.Attachments, dst)
+	}
+
+	// reserve an id and a slug for every note, so the links can be converted
+	slugs := make(map[string]bool)
+	for _, note := range notes {
+		zet := &model.Zettel{Title: note.title}
+
+		zet.ID, err = zr.NextID(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+
+		zet.Type = noteType(note.path, folders, defaultType)
+		zet.Path = cfg.FleetRoot + "/" + zet.ID + ".md"
+		if zet.Type == "permanent" {
+			zet.Path = cfg.PermanentRoot + "/" + zet.ID + ".md"
+		}
+
+		// the next NextID call sees the file and moves on
+		if err = fs.CreateExclusive(zet.Path, fmt.Sprintf("# %s\n", zet.Title)); err != nil {
+			return nil, err
+		}
+		created = append(created, zet.Path)
+
+		base := slug.Make(note.title)
+		zet.Slug = base
+		for i := 2; slugs[zet.Slug] || zr.Get(ctx, &model.Zettel{Slug: zet.Slug}) == nil; i++ {
+			zet.Slug = fmt.Sprintf("%s-%d", base, i)
+		}
+		slugs[zet.Slug] = true
+
+		note.zet = zet
+	}
+
+	// notes are linked by their filename, path, title or aliases
+	byName := make(map[string]*obsidianNote)
+	for _, note := range notes {
+		keys := append([]string{
+			strings.TrimSuffix(note.path, ".md"),
+			strings.TrimSuffix(filepath.Base(note.path), ".md"),
+			note.title,
+		}, note.aliases...)
+		for _, key := range keys {
+			key = strings.ToLower(key)
+			if _, ok := byName[key]; !ok {
+				byName[key] = note
+			}
+		}
+	}
+
+	byFile := make(map[string]string)
+	for _, rel := range attachments {
+		for _, key := range []string{rel, filepath.Base(rel)} {
+			if _, ok := byFile[key]; !ok {
+				byFile[key] = filepath.Join(cfg.AttachmentsRoot, filepath.FromSlash(rel))
+			}
+		}
+	}
+
+	zettels := make([]*model.Zettel, len(notes))
+	for i, note := range notes {
+		body := convertObsidianLinks(ctx, zr, note, byName, byFile, report)
+
+		var missing []string
+		existing := (&model.Zettel{Content: body}).Tags()
+		for _, tag := range note.tags {
+			if !contains(existing, strings.ToLower(tag)) {
+				missing = append(missing, "#"+tag)
+			}
+		}
+		if len(missing) > 0 {
+			body = strings.TrimRight(body, "\n") + "\n\n" + strings.Join(missing, " ")
+		}
+
+		note.zet.Content = strings.TrimRight(fmt.Sprintf("# %s\n\n%s", note.title, body), "\n")
+		if err = note.zet.Write(); err != nil {
+			return nil, err
+		}
+
+		zet := &model.Zettel{Path: note.zet.Path}
+		if err = zet.Read(cfg); err != nil {
+			return nil, err
+		}
+		zet.Slug = note.zet.Slug
+
+		zettels[i] = zet
+	}
+
+	//
+	// indexing phase, in one pass
+	//
+
+	if len(zettels) > 0 {
+		if err = zr.SaveBulk(ctx, zettels...); err != nil {
+			return nil, err
+		}
+	}
+
+	// the aliases keep resolving to the note, as the slugs of merged zettels
+	for i, note := range notes {
+		if len(note.aliases) == 0 {
+			continue
+		}
+		if err = zr.AddAliases(ctx, zettels[i], note.aliases...); err != nil {
+			return nil, err
+		}
+	}
+
+	var links []*model.Link
+	for _, zet := range zettels {
+		for _, link := range zet.Links {
+			// unresolved links were reported while converting them
+			if zr.Resolve(ctx, link) != nil {
+				continue
+			}
+			links = append(links, &model.Link{From: zet.ID, To: link.ID})
+		}
+	}
+
+	if len(links) > 0 {
+		if err = zr.LinkBulk(ctx, links...); err != nil {
+			return nil, err
+		}
+	}
+
+	report.Zettels = zettels
+
+	return report, nil
+}
+
+// parseObsidianNote reads the title, aliases and tags of a note from its front
+// matter
+func parseObsidianNote(rel string, content string) *obsidianNote {
+	fm, body := model.ParseFrontMatter(content)
+
+	note := &obsidianNote{
+		path:  rel,
+		title: strings.TrimSpace(fm.Get("title")),
+		body:  body,
+	}
+	if note.title == "" {
+		note.title = strings.TrimSuffix(filepath.Base(rel), ".md")
+	}
+
+	// a "# Title" heading is the title of the zettel, do not repeat it
+	lines := strings.SplitN(strings.TrimLeft(body, "\n"), "\n", 2)
+	if strings.TrimPrefix(lines[0], "# ") == note.title {
+		note.body = ""
+		if len(lines) == 2 {
+			note.body = strings.TrimLeft(lines[1], "\n")
+		}
+	}
+
+	for _, key := range []string{"aliases", "alias"} {
+		note.aliases = append(note.aliases, fm[key]...)
+	}
+
+	for _, key := range []string{"tags", "tag"} {
+		for _, value := range fm[key] {
+			for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+				if tag = strings.TrimPrefix(tag, "#"); tag != "" {
+					note.tags = append(note.tags, tag)
+				}
+			}
+		}
+	}
+
+	return note
+}
+
+// convertObsidianLinks rewrites the [[links]] and ![[embeds]] of the note to
+// slug links, or markdown links for attachments. Links that cannot be
+// resolved are kept as they are and added to the report.
+func convertObsidianLinks(ctx context.Context, zr repository.ZettelRepository, note *obsidianNote, byName map[string]*obsidianNote, byFile map[string]string, report *ImportReport) string {
+	lines := strings.Split(note.body, "\n")
+
+	fenced := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+
+		links := findWikilinks(line)
+		if len(links) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, link := range links {
+			start := link.Start
+			embed := start > 0 && line[start-1] == '!'
+			if embed {
+				start--
+			}
+
+			b.WriteString(line[last:start])
+			last = link.End
+
+			target, display, _ := strings.Cut(link.Text, "|")
+			target, heading, _ := strings.Cut(target, "#")
+			target = strings.TrimSpace(target)
+
+			// links to a heading of the same note
+			if target == "" {
+				b.WriteString(line[start:link.End])
+				continue
+			}
+
+			if path, ok := byFile[target]; ok {
+				rel, _ := filepath.Rel(filepath.Dir(note.zet.Path), path)
+				if display == "" {
+					display = filepath.Base(target)
+				}
+				if embed {
+					b.WriteString("!")
+				}
+				b.WriteString(fmt.Sprintf("[%s](<%s>)", display, filepath.ToSlash(rel)))
+				continue
+			}
+
+			var s string
+			if other, ok := byName[strings.ToLower(strings.TrimSuffix(target, ".md"))]; ok {
+				s = other.zet.Slug
+			} else if zet := model.NewLink(target); zr.Resolve(ctx, zet) == nil {
+				s = zet.Slug
+			} else {
+				report.Unresolved = append(report.Unresolved, &UnresolvedLink{Path: note.zet.Path, Link: link.Text})
+				b.WriteString(line[start:link.End])
+				continue
+			}
+
+			if heading != "" {
+				s += "#" + heading
+			}
+			if display != "" {
+				report.Undisplayed = append(report.Undisplayed, &UnresolvedLink{Path: note.zet.Path, Link: link.Text})
+			}
+
+			if embed {
+				b.WriteString("![[" + s + "]]")
+				continue
+			}
+			b.WriteString("[[" + s + "]]")
+		}
+		b.WriteString(line[last:])
+
+		lines[i] = b.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// noteType returns the type mapped to the longest folder containing the note
+func noteType(rel string, folders map[string]string, defaultType string) string {
+	keys := make([]string, 0, len(folders))
+	for folder := range folders {
+		keys = append(keys, folder)
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+
+	for _, folder := range keys {
+		prefix := strings.Trim(filepath.ToSlash(folder), "/") + "/"
+		if strings.HasPrefix(rel, prefix) {
+			return folders[folder]
+		}
+	}
+
+	return defaultType
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+func TestImportObsidian(t *testing.T) {
+	t.Run("vault -> zettels, links, tags, attachments", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, cfg := startup(t)
+		vault := t.TempDir()
+
+		files := map[string]string{
+			"Inbox/First Note.md":      "---\ntags: [idea, reading]\naliases:\n  - The First\n---\n# First Note\n\nSee [[Second#Intro|the second one]] and [[Missing Note]].\n",
+			"Notes/Second.md":          "Embeds ![[The First#Intro]] and ![[diagram.png]] #inline\n",
+			"Notes/assets/diagram.png": "png",
+			".obsidian/app.json":       "{}",
+		}
+		for rel, content := range files {
+			path := filepath.Join(vault, filepath.FromSlash(rel))
+			require.Equal(t, os.MkdirAll(filepath.Dir(path), 0755), nil, "failed to create vault dir")
+			require.Equal(t, os.WriteFile(path, []byte(content), 0644), nil, "failed to write vault file")
+		}
+
+		report, err := ImportObsidian(zr, vault, map[string]string{"Notes": "permanent"}, "fleet")
+		require.Equal(t, err, nil, "failed to import vault")
+		require.Equal(t, len(report.Zettels), 2, "two notes should be imported")
+		require.Equal(t, len(report.Attachments), 1, "one attachment should be copied")
+		require.Equal(t, len(report.Unresolved), 1, "one link should be unresolved")
+		assert.Equal(t, report.Unresolved[0].Link, "Missing Note", "missing note should be reported")
+
+		first := &model.Zettel{Slug: "first-note"}
+		require.Equal(t, zr.Get(context.Background(), first), nil, "first note should be indexed")
+		assert.Equal(t, first.Type, "fleet", "first note should be fleet")
+		assert.Equal(t, strings.Contains(first.Content, "[[second#Intro]]"), true, "link should be converted to a slug with its heading")
+		require.Equal(t, len(report.Undisplayed), 1, "one display text should be dropped")
+		assert.Equal(t, report.Undisplayed[0].Link, "Second#Intro|the second one", "dropped display text should be reported")
+		assert.Equal(t, strings.Join(first.Tags(), " "), "idea reading", "front matter tags should be kept")
+
+		second := &model.Zettel{Slug: "second"}
+		require.Equal(t, zr.Get(context.Background(), second), nil, "second note should be indexed")
+		assert.Equal(t, second.Type, "permanent", "mapped folder should be permanent")
+		assert.Equal(t, strings.HasPrefix(second.Path, cfg.PermanentRoot), true, "second note should be in permanent")
+		assert.Equal(t, strings.Contains(second.Content, "![[first-note#Intro]]"), true, "embed should be converted")
+		assert.Equal(t, strings.Contains(second.Content, "![diagram.png](<../attachments/Notes/assets/diagram.png>)"), true, "attachment should be linked")
+		assert.Equal(t, fs.Exists(filepath.Join(cfg.AttachmentsRoot, "Notes", "assets", "diagram.png")), true, "attachment should be copied")
+
+		backlinks, err := zr.Backlinks(context.Background(), &model.Zettel{ID: second.ID})
+		require.Equal(t, err, nil, "failed to get backlinks")
+		require.Equal(t, len(backlinks), 1, "second note should have a backlink")
+		assert.Equal(t, backlinks[0].ID, first.ID, "first note should link to the second")
+
+		aliases, err := zr.Aliases(context.Background(), first)
+		require.Equal(t, err, nil, "failed to get the aliases")
+		assert.Equal(t, strings.Join(aliases, " "), "the-first", "front matter aliases should be kept")
+
+		alias := model.NewLink("The First")
+		require.Equal(t, zr.Resolve(context.Background(), alias), nil, "alias should resolve")
+		assert.Equal(t, alias.ID, first.ID, "alias should resolve to the first note")
+
+		_, err = ImportObsidian(zr, vault, nil, "fleet")
+		assert.Equal(t, errors.Is(err, os.ErrExist), true, "existing attachments should not be overwritten")
+	})
+}
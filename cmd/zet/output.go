@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// Formats of the output, see --format
+const (
+	// one JSON document
+	FormatJSON = "json"
+	// a JSON document per record
+	FormatNDJSON = "ndjson"
+	// aligned columns with a header
+	FormatTable = "table"
+	// tab separated columns, without a header
+	FormatTSV = "tsv"
+	// the path, or the id, then the other columns, tab separated, for
+	// fzf --delimiter '\t' --with-nth 2..
+	FormatFzf = "fzf"
+	// the path of every record
+	FormatPaths = "paths"
+)
+
+var formats = []string{FormatJSON, FormatNDJSON, FormatTable, FormatTSV, FormatFzf, FormatPaths}
+
+// the columns of table, tsv and fzf when no fields are given, in this order,
+// the ones the records have
+var defaultFields = []string{"id", "zettelId", "slug", "title", "kind", "action", "rule", "message", "type", "path", "createdAt"}
+
+// Output writes the results of the commands in a format. A record is an
+// element of a list, or the whole result when it is not a list. Records are
+// read by their JSON keys, nested ones like review.dueAt.
+type Output struct {
+	Format string
+	// only these fields of the records, in this order
+	Fields []string
+	// executed on every record instead of the format, with the Go value
+	Template *template.Template
+}
+
+// NewOutput returns the output for the format, the comma separated fields and
+// the template, which can be empty
+func NewOutput(format string, fields string, tmpl string) (*Output, error) {
+	if !contains(formats, format) {
+		return nil, usageErrorf("error: invalid format %q, expected %s", format, strings.Join(formats, ", "))
+	}
+
+	o := &Output{Format: format}
+
+	for _, field := range strings.Split(fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			o.Fields = append(o.Fields, field)
+		}
+	}
+
+	if tmpl != "" {
+		t, err := template.New("output").Parse(tmpl)
+		if err != nil {
+			return nil, usageErrorf("error: invalid template: %v", err)
+		}
+		o.Template = t
+	}
+
+	return o, nil
+}
+
+// Write writes the value to w, every line ends with a newline
+func (o *Output) Write(w io.Writer, v any) error {
+	if o.Template != nil {
+		return o.writeTemplate(w, v)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var doc any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return err
+	}
+
+	list, isList := doc.([]any)
+	records := list
+	if !isList && doc != nil {
+		records = []any{doc}
+	}
+
+	if err := o.checkFields(records); err != nil {
+		return err
+	}
+
+	switch o.Format {
+	case FormatJSON:
+		if len(o.Fields) == 0 {
+			// as is, in the order of the struct fields
+			_, err := fmt.Fprintln(w, string(data))
+			return err
+		}
+
+		selected := make([]any, len(records))
+		for i, r := range records {
+			selected[i] = o.selectFields(r)
+		}
+		if !isList && len(selected) == 1 {
+			return writeJSONLine(w, selected[0])
+		}
+		return writeJSONLine(w, selected)
+	case FormatNDJSON:
+		if len(o.Fields) == 0 {
+			for _, e := range elements(v) {
+				if err := writeJSONLine(w, e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		for _, r := range records {
+			if err := writeJSONLine(w, o.selectFields(r)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatPaths:
+		for _, r := range records {
+			path := fieldString(r, "path")
+			if path == "" {
+				return usageErrorf("error: the output has no paths, try another --format")
+			}
+			fmt.Fprintln(w, path)
+		}
+		return nil
+	}
+
+	fields := o.columns(records)
+
+	switch o.Format {
+	case FormatTable:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		header := make([]string, len(fields))
+		for i, field := range fields {
+			header[i] = strings.ToUpper(field)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, r := range records {
+			fmt.Fprintln(tw, strings.Join(fieldStrings(r, fields), "\t"))
+		}
+		return tw.Flush()
+	case FormatFzf:
+		key := "path"
+		if fieldString(firstRecord(records), "path") == "" {
+			key = "id"
+		}
+		rest := []string{}
+		for _, field := range fields {
+			if field != key {
+				rest = append(rest, field)
+			}
+		}
+		for _, r := range records {
+			fmt.Fprintln(w, strings.Join(append([]string{fieldString(r, key)}, fieldStrings(r, rest)...), "\t"))
+		}
+	default:
+		for _, r := range records {
+			fmt.Fprintln(w, strings.Join(fieldStrings(r, fields), "\t"))
+		}
+	}
+
+	return nil
+}
+
+func (o *Output) writeTemplate(w io.Writer, v any) error {
+	var b bytes.Buffer
+	for _, r := range elements(v) {
+		b.Reset()
+		if err := o.Template.Execute(&b, r); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, strings.TrimRight(b.String(), "\n"))
+	}
+	return nil
+}
+
+// checkFields refuses the fields that none of the records have, most likely a
+// typo
+func (o *Output) checkFields(records []any) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	for _, field := range o.Fields {
+		found := false
+		for _, r := range records {
+			if _, ok := lookupField(r, field); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return usageErrorf("error: unknown field %q", field)
+		}
+	}
+
+	return nil
+}
+
+// columns returns the fields, the default ones the records have or else all
+// the keys of the first record
+func (o *Output) columns(records []any) []string {
+	if len(o.Fields) > 0 {
+		return o.Fields
+	}
+
+	first, _ := firstRecord(records).(map[string]any)
+
+	fields := []string{}
+	for _, field := range defaultFields {
+		if _, ok := first[field]; ok {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) > 0 {
+		return fields
+	}
+
+	for key := range first {
+		fields = append(fields, key)
+	}
+	sort.Strings(fields)
+
+	if len(fields) == 0 {
+		// a list of strings or numbers
+		return []string{""}
+	}
+
+	return fields
+}
+
+// selectFields returns the record with only the fields, in their order
+func (o *Output) selectFields(record any) any {
+	selected := &orderedRecord{}
+	for _, field := range o.Fields {
+		value, _ := lookupField(record, field)
+		selected.keys = append(selected.keys, field)
+		selected.values = append(selected.values, value)
+	}
+	return selected
+}
+
+// orderedRecord marshals its keys in order, as given by --fields
+type orderedRecord struct {
+	keys   []string
+	values []any
+}
+
+func (r *orderedRecord) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// lookupField returns the value of a dotted field like review.dueAt, the
+// empty field is the record itself
+func lookupField(record any, field string) (any, bool) {
+	if field == "" {
+		return record, true
+	}
+
+	value := record
+	for _, key := range strings.Split(field, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func fieldStrings(record any, fields []string) []string {
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i] = fieldString(record, field)
+	}
+	return values
+}
+
+// fieldString returns the value of the field on a single line, lists of
+// strings are comma separated and objects are JSON
+func fieldString(record any, field string) string {
+	value, _ := lookupField(record, field)
+
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	case bool:
+		s = fmt.Sprint(v)
+	case []any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = fieldString(e, "")
+		}
+		s = strings.Join(parts, ",")
+	default:
+		data, _ := json.Marshal(v)
+		s = string(data)
+	}
+
+	return strings.NewReplacer("\t", " ", "\n", " ").Replace(s)
+}
+
+func firstRecord(records []any) any {
+	if len(records) == 0 {
+		return nil
+	}
+	return records[0]
+}
+
+// elements returns the elements of a slice, or the value itself, nothing for
+// nil
+func elements(v any) []any {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		return nil
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []any{v}
+	}
+
+	records := make([]any, rv.Len())
+	for i := range records {
+		records[i] = rv.Index(i).Interface()
+	}
+	return records
+}
+
+func writeJSONLine(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
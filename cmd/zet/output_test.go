@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/model"
+)
+
+func TestOutput(t *testing.T) {
+	zettels := []*model.Zettel{
+		{ID: "1", Slug: "a-title-one", Title: "A title one", Type: "fleet", Path: "/tmp/zet/fleet/1.md"},
+		{ID: "2", Slug: "a-title-two", Title: "A title\ttwo", Type: "permanent", Path: "/tmp/zet/permanent/a-title-two.md"},
+	}
+
+	write := func(t *testing.T, format string, fields string, tmpl string, v any) string {
+		out, err := NewOutput(format, fields, tmpl)
+		require.Equal(t, err, nil, "failed to create the output")
+
+		var b strings.Builder
+		err = out.Write(&b, v)
+		require.Equal(t, err, nil, "failed to write the output")
+		return b.String()
+	}
+
+	t.Run("json -> ndjson -> fields", func(t *testing.T) {
+		got := write(t, FormatJSON, "", "", zettels)
+		assert.Equal(t, strings.HasPrefix(got, `[{"id":"1"`), true, "json should be a list")
+		assert.Equal(t, strings.HasSuffix(got, "]\n"), true, "json should end with a newline")
+
+		got = write(t, FormatNDJSON, "title,id", "", zettels)
+		assert.Equal(t, got, "{\"title\":\"A title one\",\"id\":\"1\"}\n{\"title\":\"A title\\ttwo\",\"id\":\"2\"}\n", "ndjson should have a line per record, with the fields in order")
+
+		got = write(t, FormatJSON, "id", "", zettels[0])
+		assert.Equal(t, got, "{\"id\":\"1\"}\n", "a single record should stay an object")
+
+		got = write(t, FormatJSON, "", "", (*DueReview)(nil))
+		assert.Equal(t, got, "null\n", "nil should be null")
+
+		got = write(t, FormatJSON, "zettel.slug,review.ease", "", &DueReview{Zettel: zettels[0], Review: &model.Review{Ease: 2.5}})
+		assert.Equal(t, got, "{\"zettel.slug\":\"a-title-one\",\"review.ease\":2.5}\n", "nested fields should be selected")
+	})
+
+	t.Run("table -> tsv -> fzf -> paths", func(t *testing.T) {
+		got := write(t, FormatTable, "", "", zettels)
+		lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+		require.Equal(t, len(lines), 3, "the table should have a header and a row per record")
+		assert.Equal(t, strings.Fields(lines[0])[0], "ID", "the header should be the fields")
+		assert.Equal(t, strings.Index(lines[1], "fleet"), strings.Index(lines[2], "permanent"), "the columns should be aligned")
+
+		got = write(t, FormatTSV, "id,title", "", zettels)
+		assert.Equal(t, got, "1\tA title one\n2\tA title two\n", "tsv should escape the tabs of the values")
+
+		got = write(t, FormatFzf, "title", "", zettels)
+		assert.Equal(t, got, "/tmp/zet/fleet/1.md\tA title one\n/tmp/zet/permanent/a-title-two.md\tA title two\n", "fzf should start with the path")
+
+		got = write(t, FormatPaths, "", "", zettels)
+		assert.Equal(t, got, "/tmp/zet/fleet/1.md\n/tmp/zet/permanent/a-title-two.md\n", "paths should be a path per line")
+
+		out, _ := NewOutput(FormatPaths, "", "")
+		err := out.Write(&strings.Builder{}, []*Violation{{Rule: RuleTag, Message: "has no #tag"}})
+		assert.NotEqual(t, err, nil, "records without paths should be refused")
+	})
+
+	t.Run("template -> invalid", func(t *testing.T) {
+		got := write(t, FormatJSON, "", "{{.Title}}: {{.Type}}", zettels)
+		assert.Equal(t, got, "A title one: fleet\nA title\ttwo: permanent\n", "the template should run on every record")
+
+		got = write(t, FormatJSON, "", "{{.Slug}}", zettels[0])
+		assert.Equal(t, got, "a-title-one\n", "the template should run on a single record")
+
+		_, err := NewOutput("xml", "", "")
+		assert.NotEqual(t, err, nil, "unknown formats should be refused")
+
+		_, err = NewOutput(FormatJSON, "", "{{.Title")
+		assert.NotEqual(t, err, nil, "invalid templates should be refused")
+
+		out, _ := NewOutput(FormatTSV, "titel", "")
+		err = out.Write(&strings.Builder{}, zettels)
+		assert.NotEqual(t, err, nil, "unknown fields should be refused")
+	})
+}
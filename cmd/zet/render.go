@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+)
+
+// MaxEmbedDepth is how deep embeds inside embeds are expanded by default
+const MaxEmbedDepth = 5
+
+// Render returns the content of the zettel given by its path, id, slug or
+// title with its ![[embeds]] expanded, up to the given depth, see
+// renderEmbeds
+func Render(zr repository.ZettelRepository, arg string, depth int) (string, error) {
+	zet, err := findZettel(zr, arg)
+	if err != nil {
+		return "", err
+	}
+
+	return renderEmbeds(context.Background(), zr, zet, depth), nil
+}
+
+// renderEmbeds replaces the ![[slug]] and ![[slug#heading]] embeds of the
+// zettel with the body of the embedded zettel, or the section under the
+// heading, expanding their own embeds up to the depth. Embeds that cannot be
+// resolved, that would loop or that are too deep are left as [[links]].
+// Fenced code blocks are kept as is.
+func renderEmbeds(ctx context.Context, zr repository.ZettelRepository, zet *model.Zettel, depth int) string {
+	return expandEmbeds(ctx, zr, zet.Content, depth, map[string]bool{embedKey(zet.ID, ""): true})
+}
+
+func expandEmbeds(ctx context.Context, zr repository.ZettelRepository, content string, depth int, seen map[string]bool) string {
+	lines := strings.Split(content, "\n")
+
+	fenced := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+
+		links := findWikilinks(line)
+		if len(links) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, link := range links {
+			if link.Start == 0 || line[link.Start-1] != '!' {
+				continue
+			}
+
+			b.WriteString(line[last : link.Start-1])
+			last = link.End
+
+			b.WriteString(expandEmbed(ctx, zr, link.Text, depth, seen))
+		}
+		b.WriteString(line[last:])
+
+		lines[i] = b.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// expandEmbed returns the text an embed expands to
+func expandEmbed(ctx context.Context, zr repository.ZettelRepository, text string, depth int, seen map[string]bool) string {
+	unexpanded := "[[" + text + "]]"
+	if depth <= 0 {
+		return unexpanded
+	}
+
+	target := model.NewLink(text)
+	if err := zr.Resolve(ctx, target); err != nil {
+		return unexpanded
+	}
+
+	_, heading, _ := strings.Cut(text, "#")
+	key := embedKey(target.ID, heading)
+	if embedsItself(seen, target.ID, key) {
+		return unexpanded
+	}
+
+	body, ok := embedBody(target.Content, heading)
+	if !ok {
+		return unexpanded
+	}
+
+	seen[key] = true
+	defer delete(seen, key)
+
+	return expandEmbeds(ctx, zr, body, depth-1, seen)
+}
+
+// embedBody returns the content without its title, or the section under the
+// heading with the heading itself, until the next heading of the same level
+// or above. Headings match by their slug.
+func embedBody(content string, heading string) (string, bool) {
+	lines := strings.Split(content, "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "# ") {
+		lines = lines[1:]
+	}
+
+	if heading == "" {
+		return strings.TrimSpace(strings.Join(lines, "\n")), true
+	}
+
+	want := slug.Make(heading)
+	start, level := -1, 0
+
+	fenced := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+
+		l, text := headingLevel(line)
+		if l == 0 {
+			continue
+		}
+		if start >= 0 && l <= level {
+			return strings.TrimSpace(strings.Join(lines[start:i], "\n")), true
+		}
+		if start < 0 && slug.Make(text) == want {
+			start, level = i, l
+		}
+	}
+
+	if start < 0 {
+		return "", false
+	}
+
+	return strings.TrimSpace(strings.Join(lines[start:], "\n")), true
+}
+
+// headingLevel returns the level and the text of a markdown heading, a level
+// of 0 when the line is not one
+func headingLevel(line string) (int, string) {
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level == 0 || level > 6 || (len(line) > level && line[level] != ' ') {
+		return 0, ""
+	}
+
+	return level, strings.TrimSpace(line[level:])
+}
+
+// embedsItself reports whether the embed is already being expanded: the
+// same section, the whole zettel it is part of or, for a whole zettel, any
+// of its sections
+func embedsItself(seen map[string]bool, id string, key string) bool {
+	if seen[key] || seen[id] {
+		return true
+	}
+	if key != id {
+		return false
+	}
+
+	for k := range seen {
+		if strings.HasPrefix(k, id+"#") {
+			return true
+		}
+	}
+	return false
+}
+
+func embedKey(id string, heading string) string {
+	if heading == "" {
+		return id
+	}
+	return id + "#" + slug.Make(heading)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+)
+
+func TestRender(t *testing.T) {
+	t.Run("embeds -> sections -> nested -> cycles -> depth", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z2 := createZet(t, zr, "A title two")
+		z3 := createZet(t, zr, "A title three")
+
+		z3.Content = fmt.Sprintf("# A title three\n\nA third line, back to ![[%s]]", z1.Slug)
+		require.Equal(t, z3.Write(), nil, "failed to write z3")
+		saveZet(t, zr, z3)
+
+		z2.Content = fmt.Sprintf("# A title two\n\nA second line\n\n## An intro\n\nAn intro line\n\n### A detail\n\nA detail line, with ![[%s]]\n\n## An outro\n\nAn outro line", z3.Slug)
+		require.Equal(t, z2.Write(), nil, "failed to write z2")
+		saveZet(t, zr, z2)
+
+		z1.Content = fmt.Sprintf("# A title one\n\n![[%s#An intro]]\n\n![[%s#A missing heading]]\n\n```\n![[%s]]\n```", z2.Slug, z2.Slug, z2.Slug)
+		require.Equal(t, z1.Write(), nil, "failed to write z1")
+		saveZet(t, zr, z1)
+
+		content, err := Render(zr, z1.ID, MaxEmbedDepth)
+		require.Equal(t, err, nil, "failed to render z1")
+		assert.Equal(t, strings.Contains(content, "## An intro\n\nAn intro line\n\n### A detail"), true, "the section should be embedded with its subsections")
+		assert.Equal(t, strings.Contains(content, "An outro line"), false, "the next section should not be embedded")
+		assert.Equal(t, strings.Contains(content, "A detail line, with A third line"), true, "nested embeds should be expanded")
+		assert.Equal(t, strings.Contains(content, "back to [["+z1.Slug+"]]"), true, "cycles should be left as links")
+		assert.Equal(t, strings.Contains(content, "\n[["+z2.Slug+"#A missing heading]]\n"), true, "missing headings should be left as links")
+		assert.Equal(t, strings.Contains(content, "```\n![["+z2.Slug+"]]\n```"), true, "code blocks should be kept")
+
+		content, err = Render(zr, z1.ID, 1)
+		require.Equal(t, err, nil, "failed to render z1")
+		assert.Equal(t, strings.Contains(content, "A detail line, with [["+z3.Slug+"]]"), true, "embeds past the depth should be left as links")
+	})
+
+	t.Run("html export expands embeds", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z1.WriteLine("An embedded line")
+		saveZet(t, zr, z1)
+
+		z2 := createZet(t, zr, "A title two")
+		z2.WriteLine(fmt.Sprintf("![[%s]]", z1.Slug))
+		saveZet(t, zr, z2)
+
+		out := t.TempDir()
+		_, err := ExportHTML(zr, out, true)
+		require.Equal(t, err, nil, "failed to export")
+
+		page, err := os.ReadFile(filepath.Join(out, z2.Slug+".html"))
+		require.Equal(t, err, nil, "failed to read the z2 page")
+		assert.Equal(t, strings.Contains(string(page), "An embedded line"), true, "the embed should be expanded")
+		assert.Equal(t, strings.Contains(string(page), "<img"), false, "the embed should not be an image")
+	})
+}
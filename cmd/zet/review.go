@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+)
+
+// DueReview is the next zettel to review, with its schedule
+type DueReview struct {
+	Zettel *model.Zettel `json:"zettel"`
+	Review *model.Review `json:"review"`
+}
+
+// NextReview schedules the new permanent zettels and returns the one that is
+// due the longest, nil when nothing is due
+func NextReview(zr repository.ZettelRepository) (*DueReview, error) {
+	ctx := context.Background()
+
+	if _, err := zr.ScheduleReviews(ctx); err != nil {
+		return nil, err
+	}
+
+	zet, review, err := zr.NextReview(ctx, time.Now())
+	if errors.Is(err, repository.ErrNothingDue) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &DueReview{Zettel: zet, Review: review}, nil
+}
+
+// RateReview records how well the zettel was recalled, from 1 to 5, and
+// schedules its next review
+func RateReview(zr repository.ZettelRepository, arg string, rating int) (*model.Review, error) {
+	zet, err := findZettel(zr, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	// the zettel might have become permanent since the last `zet review`
+	if _, err := zr.ScheduleReviews(ctx); err != nil {
+		return nil, err
+	}
+
+	return zr.RateReview(ctx, zet.ID, rating, time.Now())
+}
+
+// ReviewStats returns the number of zettels due for review
+func ReviewStats(zr repository.ZettelRepository) (*model.ReviewStats, error) {
+	ctx := context.Background()
+
+	if _, err := zr.ScheduleReviews(ctx); err != nil {
+		return nil, err
+	}
+
+	return zr.ReviewStats(ctx, time.Now())
+}
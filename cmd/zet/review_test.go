@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/repository"
+)
+
+func TestReview(t *testing.T) {
+	t.Run("permanent -> review -> rate -> rescheduled", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+
+		due, err := NextReview(zr)
+		require.Equal(t, err, nil, "failed to query the review queue")
+		assert.Equal(t, due == nil, true, "fleet zettels should not be reviewed")
+
+		z1 := createZet(t, zr, "A title one")
+		z1, err = Permanent(zr, z1.Path, false)
+		require.Equal(t, err, nil, "failed to make z1 permanent")
+
+		stats, err := ReviewStats(zr)
+		require.Equal(t, err, nil, "failed to count the reviews")
+		assert.Equal(t, stats.Due, 1, "new permanent zettels should be due")
+		assert.Equal(t, stats.Total, 1, "new permanent zettels should be scheduled")
+
+		due, err = NextReview(zr)
+		require.Equal(t, err, nil, "failed to query the review queue")
+		require.NotEqual(t, due, nil, "z1 should be due")
+		assert.Equal(t, due.Zettel.ID, z1.ID, "z1 should be served")
+
+		_, err = RateReview(zr, z1.ID, 6)
+		assert.Equal(t, errors.Is(err, repository.ErrInvalidRating), true, "ratings above 5 should be refused")
+
+		z2 := createZet(t, zr, "A title two")
+		_, err = RateReview(zr, z2.ID, 5)
+		assert.Equal(t, errors.Is(err, repository.ErrInvalidZettel), true, "fleet zettels should not be rated")
+
+		review, err := RateReview(zr, z1.Slug, 5)
+		require.Equal(t, err, nil, "failed to rate z1")
+		assert.Equal(t, review.Repetitions, 1, "recall should count as a repetition")
+		assert.Equal(t, review.Interval, 1, "first interval should be a day")
+		assert.Equal(t, review.Ease, 2.6, "perfect recall should make it easier")
+		assert.Equal(t, review.DueAt.T.After(time.Now().Add(23*time.Hour)), true, "next review should be in a day")
+
+		review, err = RateReview(zr, z1.ID, 4)
+		require.Equal(t, err, nil, "failed to rate z1")
+		assert.Equal(t, review.Interval, 6, "second interval should be 6 days")
+
+		review, err = RateReview(zr, z1.ID, 4)
+		require.Equal(t, err, nil, "failed to rate z1")
+		assert.Equal(t, review.Interval, 16, "next intervals should grow with the ease")
+
+		review, err = RateReview(zr, z1.ID, 1)
+		require.Equal(t, err, nil, "failed to rate z1")
+		assert.Equal(t, review.Repetitions, 0, "forgetting should start over")
+		assert.Equal(t, review.Interval, 1, "forgetting should review it the next day")
+
+		stats, err = ReviewStats(zr)
+		require.Equal(t, err, nil, "failed to count the reviews")
+		assert.Equal(t, stats.Due, 0, "rated zettels should not be due")
+
+		due, err = NextReview(zr)
+		require.Equal(t, err, nil, "failed to query the review queue")
+		assert.Equal(t, due == nil, true, "nothing should be due")
+	})
+
+	t.Run("remove -> restore -> the schedule is back", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z1, err := Permanent(zr, z1.Path, false)
+		require.Equal(t, err, nil, "failed to make z1 permanent")
+
+		rated, err := RateReview(zr, z1.ID, 5)
+		require.Equal(t, err, nil, "failed to rate z1")
+
+		_, err = Remove(zr, z1.Path)
+		require.Equal(t, err, nil, "failed to remove z1")
+
+		stats, err := ReviewStats(zr)
+		require.Equal(t, err, nil, "failed to count the reviews")
+		assert.Equal(t, stats.Total, 0, "removed zettels should not be scheduled")
+
+		_, err = Restore(zr, z1.ID)
+		require.Equal(t, err, nil, "failed to restore z1")
+
+		stats, err = ReviewStats(zr)
+		require.Equal(t, err, nil, "failed to count the reviews")
+		assert.Equal(t, stats.Total, 1, "restored zettels should be scheduled")
+		assert.Equal(t, stats.Due, 0, "restored zettels should keep their due date")
+
+		review, err := RateReview(zr, z1.ID, 4)
+		require.Equal(t, err, nil, "failed to rate the restored z1")
+		assert.Equal(t, review.Repetitions, rated.Repetitions+1, "restored zettels should keep their repetitions")
+	})
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/odas0r/zet/internal/config"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/fs"
+	"github.com/odas0r/zet/pkg/git"
+	"github.com/odas0r/zet/pkg/jsonrpc"
+)
+
+// Application error codes of the rpc mode
+const (
+	rpcCodeNotFound  = -32001
+	rpcCodeNoZettel  = -32002
+	rpcCodeAmbiguous = -32003
+	rpcCodeConflict  = -32004
+	rpcCodeNotReady  = -32005
+)
+
+type rpcParams struct {
+	Path      string `json:"path"`
+	ID        string `json:"id"`
+	Slug      string `json:"slug"`
+	Title     string `json:"title"`
+	Parent    string `json:"parent"`
+	Query     string `json:"query"`
+	Fix       bool   `json:"fix"`
+	OlderThan string `json:"olderThan"`
+	Rating    int    `json:"rating"`
+	Since     string `json:"since"`
+	Kind      string `json:"kind"`
+	Limit     int    `json:"limit"`
+	Period    string `json:"period"`
+	Sort      string `json:"sort"`
+	Stale     bool   `json:"stale"`
+	Force     bool   `json:"force"`
+	Into      string `json:"into"`
+	Lines     string `json:"lines"`
+	Depth     int    `json:"depth"`
+	Rev       string `json:"rev"`
+	// a dump to restore
+	File string `json:"file"`
+	// an obsidian vault to import, its folders mapped to a type and the type
+	// of the other notes
+	Dir  string            `json:"dir"`
+	Map  map[string]string `json:"map"`
+	Type string            `json:"type"`
+	// the directory of an export
+	Out   string `json:"out"`
+	Fleet bool   `json:"fleet"`
+}
+
+type rpcMethod func(ctx context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error)
+
+// rpcMethods maps every command to a method, the params are the flags and
+// arguments of the command. Triage, which is interactive, the servers and
+// completion are left out.
+var rpcMethods = map[string]rpcMethod{
+	"new": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		if strings.TrimSpace(p.Title) == "" {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "missing title")
+		}
+		return New(zr, p.Title, p.Parent)
+	},
+	"get": func(ctx context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		zet := &model.Zettel{ID: p.ID, Path: p.Path, Slug: p.Slug}
+		if err := zr.Get(ctx, zet); err != nil {
+			return nil, err
+		}
+		return &zettelResponse{Zettel: zet, Content: zet.Content, Links: zet.Links}, nil
+	},
+	"open": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		// the editor opens the file, only the event is recorded
+		return Open(zr, p.Path)
+	},
+	"save": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return Save(zr, p.Path)
+	},
+	"search": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return Search(zr, p.Query)
+	},
+	"remove": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return Remove(zr, p.Path)
+	},
+	"history": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		filter, err := rpcEventFilter(p)
+		if err != nil {
+			return nil, err
+		}
+		return History(zr, filter)
+	},
+	"history.recent": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		filter, err := rpcEventFilter(p)
+		if err != nil {
+			return nil, err
+		}
+		return Recent(zr, filter)
+	},
+	"backlog": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		filter := &BacklogFilter{Sort: p.Sort, Stale: p.Stale}
+		if p.OlderThan != "" {
+			d, err := parseDuration(p.OlderThan)
+			if err != nil {
+				return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+			}
+			filter.OlderThan = d
+		}
+		return Backlog(zr, filter)
+	},
+	"archive": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return Archive(zr, p.Path)
+	},
+	"merge": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		if p.Into == "" {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "missing into")
+		}
+		return Merge(zr, p.Path, p.Into)
+	},
+	"split": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		var ranges []*LineRange
+		if p.Lines != "" {
+			parsed, err := ParseLineRanges(p.Lines)
+			if err != nil {
+				return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+			}
+			ranges = parsed
+		}
+		return Split(zr, p.Path, ranges)
+	},
+	"render": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		depth := p.Depth
+		if depth == 0 {
+			depth = MaxEmbedDepth
+		}
+		return Render(zr, p.Path, depth)
+	},
+	"links": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return Links(zr, p.Path)
+	},
+	"backlinks": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return BackLinks(zr, p.Path)
+	},
+	"brokenlinks": func(_ context.Context, zr repository.ZettelRepository, _ *rpcParams) (any, error) {
+		return BrokenLinks(zr)
+	},
+	"permanent": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return Permanent(zr, p.Path, p.Force)
+	},
+	"lint": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return Lint(zr, p.Path)
+	},
+	"fleet": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return Fleet(zr, p.Path)
+	},
+	"last": func(_ context.Context, zr repository.ZettelRepository, _ *rpcParams) (any, error) {
+		return Last(zr)
+	},
+	"sync": func(_ context.Context, zr repository.ZettelRepository, _ *rpcParams) (any, error) {
+		return Sync(zr)
+	},
+	"doctor": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return Doctor(zr, p.Fix)
+	},
+	"graph": func(_ context.Context, zr repository.ZettelRepository, _ *rpcParams) (any, error) {
+		return ZettelGraph(zr)
+	},
+	"git.sync": func(_ context.Context, zr repository.ZettelRepository, _ *rpcParams) (any, error) {
+		report, err := GitSync(zr)
+		if errors.Is(err, git.ErrConflict) {
+			// the conflicting zettels are in the report
+			return nil, &jsonrpc.Error{Code: rpcCodeConflict, Message: err.Error(), Data: report}
+		}
+		return report, err
+	},
+	"log": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return GitLog(zr, p.Path)
+	},
+	"diff": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return GitDiff(zr, p.Path, p.Rev)
+	},
+	"show": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		if p.Rev == "" {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "missing rev")
+		}
+		return GitShow(zr, p.Path+"@"+p.Rev)
+	},
+	"dump": func(ctx context.Context, zr repository.ZettelRepository, _ *rpcParams) (any, error) {
+		// the records of zet dump, as a list
+		return zr.Dump(ctx)
+	},
+	"restore": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		if p.File == "" {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "missing file")
+		}
+
+		f, err := os.Open(p.File)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return RestoreDump(zr, f)
+	},
+	"import.obsidian": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		typ := p.Type
+		if typ == "" {
+			typ = "fleet"
+		}
+		if p.Dir == "" {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "missing dir")
+		}
+		return ImportObsidian(zr, p.Dir, p.Map, typ)
+	},
+	"export.html": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		if p.Out == "" {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "missing out")
+		}
+		return ExportHTML(zr, p.Out, p.Fleet)
+	},
+	"export.markdown": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		if p.Out == "" {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "missing out")
+		}
+		return ExportMarkdown(zr, p.Out)
+	},
+	"review": func(_ context.Context, zr repository.ZettelRepository, _ *rpcParams) (any, error) {
+		return NextReview(zr)
+	},
+	"review.rate": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return RateReview(zr, p.ID, p.Rating)
+	},
+	"review.due": func(_ context.Context, zr repository.ZettelRepository, _ *rpcParams) (any, error) {
+		return ReviewStats(zr)
+	},
+	"stats": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		period := p.Period
+		if period == "" {
+			period = PeriodWeek
+		}
+
+		since := 26 * 7 * 24 * time.Hour
+		if p.Since != "" {
+			d, err := parseDuration(p.Since)
+			if err != nil {
+				return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+			}
+			since = d
+		}
+
+		return Stats(zr, period, since)
+	},
+	"trash.list": func(_ context.Context, zr repository.ZettelRepository, _ *rpcParams) (any, error) {
+		return TrashList(zr)
+	},
+	"trash.restore": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		return Restore(zr, p.ID)
+	},
+	"trash.empty": func(_ context.Context, zr repository.ZettelRepository, p *rpcParams) (any, error) {
+		var olderThan time.Duration
+		if p.OlderThan != "" {
+			d, err := parseDuration(p.OlderThan)
+			if err != nil {
+				return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+			}
+			olderThan = d
+		}
+		return EmptyTrash(zr, olderThan)
+	},
+}
+
+func rpcEventFilter(p *rpcParams) (*model.EventFilter, error) {
+	filter, err := NewEventFilter(p.Since, p.Kind, p.Limit)
+	if err != nil {
+		return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+	}
+	return filter, nil
+}
+
+// RPC serves newline delimited JSON-RPC 2.0 requests on the given reader and
+// writer, keeping the database open between requests. When watch is true, a
+// "zettel/changed" notification is sent every time a zettel file changes.
+func RPC(ctx context.Context, zr repository.ZettelRepository, r io.Reader, w io.Writer, watch bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	conn := jsonrpc.NewConn(jsonrpc.NewLineStream(r, w))
+
+	if watch {
+		go func() {
+			err := watchZettels(ctx, zr.Config(), func(event *zettelEvent) {
+				conn.Notify("zettel/changed", event)
+			})
+			if err != nil {
+				log.Printf("warning: failed to watch zettels: %v\n", err)
+			}
+		}()
+	}
+
+	return conn.Serve(ctx, func(ctx context.Context, req *jsonrpc.Request) (any, error) {
+		method, ok := rpcMethods[req.Method]
+		if !ok {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeMethodNotFound, "method not found: %s", req.Method)
+		}
+
+		params := &rpcParams{}
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, params); err != nil {
+				return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+			}
+		}
+
+		result, err := method(ctx, zr, params)
+		if err != nil {
+			return nil, rpcError(err)
+		}
+
+		return result, nil
+	})
+}
+
+// rpcError maps the repository errors to application error codes
+func rpcError(err error) error {
+	var rpcErr *jsonrpc.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+
+	var lintErr *LintError
+	if errors.As(err, &lintErr) {
+		// the broken rules are in the data
+		return &jsonrpc.Error{Code: rpcCodeNotReady, Message: err.Error(), Data: lintErr.Violations}
+	}
+
+	var usageErr *UsageError
+	if errors.As(err, &usageErr) {
+		return jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+	}
+
+	switch {
+	case errors.Is(err, repository.ErrZettelNotFound):
+		return &jsonrpc.Error{Code: rpcCodeNotFound, Message: err.Error()}
+	case errors.Is(err, repository.ErrNoZettel):
+		return &jsonrpc.Error{Code: rpcCodeNoZettel, Message: err.Error()}
+	case errors.Is(err, repository.ErrZettelAmbiguous):
+		return &jsonrpc.Error{Code: rpcCodeAmbiguous, Message: err.Error()}
+	case errors.Is(err, repository.ErrInvalidKind), errors.Is(err, repository.ErrInvalidRating), errors.Is(err, repository.ErrInvalidQuery):
+		return jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+	case errors.Is(err, repository.ErrZettelConflict), errors.Is(err, os.ErrExist):
+		return &jsonrpc.Error{Code: rpcCodeConflict, Message: err.Error()}
+	}
+
+	return err
+}
+
+type zettelEvent struct {
+	Path string `json:"path"`
+	// created, changed or removed
+	Event string `json:"event"`
+}
+
+// watchZettels calls notify every time a zettel file is created, changed or
+// removed in the fleet, permanent or archive directories, until the context
+// is cancelled. The atomic writes rename a temporary file onto the zettel, a
+// rename onto a known path is a change.
+func watchZettels(ctx context.Context, cfg *config.Config, notify func(*zettelEvent)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	known := make(map[string]bool)
+	for _, dir := range []string{cfg.FleetRoot, cfg.PermanentRoot, cfg.ArchiveRoot} {
+		if err := fs.Mkdir(dir); err != nil {
+			return err
+		}
+		if err := watcher.Add(dir); err != nil {
+			return err
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			known[filepath.Join(dir, entry.Name())] = true
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			return err
+		case e := <-watcher.Events:
+			name := filepath.Base(e.Name)
+			// skip the temporary files of the atomic writes
+			if strings.HasPrefix(name, ".") || filepath.Ext(name) != ".md" {
+				continue
+			}
+
+			event := &zettelEvent{Path: e.Name}
+			switch {
+			case e.Has(fsnotify.Create) && known[e.Name]:
+				event.Event = "changed"
+			case e.Has(fsnotify.Create):
+				event.Event = "created"
+				known[e.Name] = true
+			case e.Has(fsnotify.Write):
+				event.Event = "changed"
+			case e.Has(fsnotify.Remove), e.Has(fsnotify.Rename):
+				event.Event = "removed"
+				delete(known, e.Name)
+			default:
+				continue
+			}
+
+			notify(event)
+		}
+	}
+}
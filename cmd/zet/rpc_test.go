@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/pkg/fs"
+	"github.com/odas0r/zet/pkg/jsonrpc"
+)
+
+func TestRPC(t *testing.T) {
+	t.Run("new -> save -> backlinks -> errors", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z2 := createZet(t, zr, "A title two")
+		z2.WriteLine("Linked to [[" + z1.Slug + "]]")
+
+		in := strings.Join([]string{
+			`{"jsonrpc": "2.0", "id": 1, "method": "new", "params": {"title": "A title three"}}`,
+			`{"jsonrpc": "2.0", "id": 2, "method": "save", "params": {"path": "` + z2.Path + `"}}`,
+			`{"jsonrpc": "2.0", "id": 3, "method": "backlinks", "params": {"path": "` + z1.Path + `"}}`,
+			`{"jsonrpc": "2.0", "id": 4, "method": "get", "params": {"id": "does-not-exist"}}`,
+			`{"jsonrpc": "2.0", "id": 5, "method": "unknown"}`,
+			`{"jsonrpc": "2.0", "id": 6, "method": "new", "params": {}}`,
+			`{"jsonrpc": "2.0", "id": 7, "method": "open", "params": {"path": "` + z1.Path + `"}}`,
+		}, "\n")
+
+		var out bytes.Buffer
+		err := RPC(context.Background(), zr, strings.NewReader(in), &out, false)
+		require.Equal(t, err, nil, "rpc failed")
+
+		responses := make(map[string]*jsonrpc.Response)
+		scanner := bufio.NewScanner(&out)
+		for scanner.Scan() {
+			res := &jsonrpc.Response{}
+			require.Equal(t, json.Unmarshal(scanner.Bytes(), res), nil, "response should be json")
+			responses[string(res.ID)] = res
+		}
+
+		require.Equal(t, len(responses), 7, "every request should have a response")
+
+		z3 := &model.Zettel{}
+		json.Unmarshal(responses["1"].Result, z3)
+		assert.Equal(t, z3.Title, "A title three", "new should create the zettel")
+		assert.Equal(t, fs.Exists(z3.Path), true, "new should create the file")
+
+		var backlinks []*model.Zettel
+		json.Unmarshal(responses["3"].Result, &backlinks)
+		require.Equal(t, len(backlinks), 1, "z1 should have a backlink")
+		assert.Equal(t, backlinks[0].ID, z2.ID, "z2 should link to z1")
+
+		require.NotEqual(t, responses["4"].Error, nil, "get should fail")
+		assert.Equal(t, responses["4"].Error.Code, rpcCodeNotFound, "zettel should not be found")
+		assert.Equal(t, responses["5"].Error.Code, jsonrpc.CodeMethodNotFound, "method should not be found")
+		assert.Equal(t, responses["6"].Error.Code, jsonrpc.CodeInvalidParams, "title is missing")
+
+		opened, err := Recent(zr, &model.EventFilter{Kind: model.EventOpened})
+		require.Equal(t, err, nil, "failed to query the history")
+		require.Equal(t, len(opened), 1, "open should record the event")
+		assert.Equal(t, opened[0].ID, z1.ID, "z1 should be opened")
+	})
+
+	t.Run("notifies when a zettel changes", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		_, cfg := startup(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		events := make(chan *zettelEvent, 10)
+		go watchZettels(ctx, cfg, func(e *zettelEvent) {
+			events <- e
+		})
+
+		// give the watcher some time to start
+		time.Sleep(100 * time.Millisecond)
+
+		path := cfg.FleetRoot + "/20230801163147123.md"
+		require.Equal(t, fs.CreateExclusive(path, "# A title\n"), nil, "failed to create file")
+
+		select {
+		case e := <-events:
+			assert.Equal(t, e.Path, path, "event should be for the new file")
+			assert.Equal(t, e.Event, "created", "file should be created")
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event received")
+		}
+
+		// the next event of the path, skipping the writes of the other files
+		next := func(path string) *zettelEvent {
+			for {
+				select {
+				case e := <-events:
+					if e.Path == path {
+						return e
+					}
+				case <-time.After(2 * time.Second):
+					t.Fatal("no event received")
+				}
+			}
+		}
+
+		archived := cfg.ArchiveRoot + "/20230801163147124.md"
+		require.Equal(t, fs.WriteAtomic(archived, "# A title\n"), nil, "failed to write the archived file")
+		assert.Equal(t, next(archived).Event, "created", "the archive should be watched")
+
+		require.Equal(t, fs.WriteAtomic(archived, "# A new title\n"), nil, "failed to rewrite the archived file")
+		assert.Equal(t, next(archived).Event, "changed", "an atomic write should be a change")
+	})
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+// Server exposes the zettel repository as a JSON API:
+//
+//	GET  /zettels?type=fleet|permanent  list the zettels
+//	POST /zettels                       create a zettel {"title": "..."}
+//	GET  /zettels/:id                   get a zettel with its content and links
+//	PUT  /zettels/:id                   save a zettel, optionally replacing its content {"content": "..."}
+//	GET  /zettels/:id/backlinks         zettels linking to the zettel
+//	GET  /search?q=                     full text search
+//	GET  /history?since=&kind=&limit=   last opened zettels, once each
+//	GET  /graph                         every zettel and link
+type Server struct {
+	zr  repository.ZettelRepository
+	mux *http.ServeMux
+}
+
+type zettelResponse struct {
+	*model.Zettel
+	Content string          `json:"content"`
+	Links   []*model.Zettel `json:"links"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewServer(zr repository.ZettelRepository) *Server {
+	s := &Server{
+		zr:  zr,
+		mux: http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/zettels", s.handleZettels)
+	s.mux.HandleFunc("/zettels/", s.handleZettel)
+	s.mux.HandleFunc("/search", s.handleSearch)
+	s.mux.HandleFunc("/history", s.handleHistory)
+	s.mux.HandleFunc("/graph", s.handleGraph)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleZettels(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var (
+			zettels []*model.Zettel
+			err     error
+		)
+
+		switch r.URL.Query().Get("type") {
+		case "fleet":
+			zettels, err = s.zr.ListFleet(r.Context())
+		case "permanent":
+			zettels, err = s.zr.ListPermanent(r.Context())
+		case "":
+			zettels, err = s.zr.ListAll(r.Context())
+		default:
+			writeError(w, http.StatusBadRequest, errors.New("error: type must be fleet or permanent"))
+			return
+		}
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, zettels)
+	case http.MethodPost:
+		var body struct {
+			Title  string `json:"title"`
+			Parent string `json:"parent"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if strings.TrimSpace(body.Title) == "" {
+			writeError(w, http.StatusBadRequest, errors.New("error: title cannot be empty"))
+			return
+		}
+
+		zet, err := New(s.zr, body.Title, body.Parent)
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, zet)
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleZettel handles /zettels/:id and /zettels/:id/backlinks
+func (s *Server) handleZettel(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/zettels/"), "/"), "/")
+
+	id := parts[0]
+	if id == "" || len(parts) > 2 || (len(parts) == 2 && parts[1] != "backlinks") {
+		writeError(w, http.StatusNotFound, errors.New("error: not found"))
+		return
+	}
+
+	zet := &model.Zettel{ID: id}
+	if err := s.zr.Get(r.Context(), zet); err != nil {
+		writeRepositoryError(w, err)
+		return
+	}
+
+	if len(parts) == 2 {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		backlinks, err := s.zr.Backlinks(r.Context(), &model.Zettel{ID: zet.ID})
+		if err != nil {
+			writeRepositoryError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, backlinks)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, &zettelResponse{Zettel: zet, Content: zet.Content, Links: zet.Links})
+	case http.MethodPut:
+		var body struct {
+			Content *string `json:"content"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		// the file is only written when the content would save
+		var original string
+		if body.Content != nil {
+			if err := checkContent(r.Context(), s.zr, *body.Content); err != nil {
+				writeRepositoryError(w, err)
+				return
+			}
+
+			var err error
+			original, err = fs.Read(zet.Path)
+			if err != nil {
+				writeRepositoryError(w, err)
+				return
+			}
+
+			zet.Content = *body.Content
+			if err := zet.Write(); err != nil {
+				writeRepositoryError(w, err)
+				return
+			}
+		}
+
+		saved, err := Save(s.zr, zet.Path)
+		if err != nil {
+			if body.Content != nil {
+				// put the note back, so the file and the database agree
+				err = withUndo(err, fs.WriteAtomic(zet.Path, original))
+			}
+			writeRepositoryError(w, err)
+			return
+		}
+		zet = saved
+
+		writeJSON(w, http.StatusOK, &zettelResponse{Zettel: zet, Content: zet.Content, Links: zet.Links})
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPut)
+	}
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		writeError(w, http.StatusBadRequest, errors.New("error: missing query parameter q"))
+		return
+	}
+
+	zettels, err := Search(s.zr, query)
+	if err != nil {
+		writeRepositoryError(w, err)
+		return
+	}
+
+	if zettels == nil {
+		zettels = []*model.Zettel{}
+	}
+
+	writeJSON(w, http.StatusOK, zettels)
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	q := r.URL.Query()
+
+	limit := 50
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("error: invalid limit %q", v))
+			return
+		}
+		limit = n
+	}
+
+	filter, err := NewEventFilter(q.Get("since"), q.Get("kind"), limit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	zettels, err := Recent(s.zr, filter)
+	if err != nil {
+		writeRepositoryError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, zettels)
+}
+
+func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	graph, err := ZettelGraph(s.zr)
+	if err != nil {
+		writeRepositoryError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, graph)
+}
+
+// checkContent refuses the content of a zettel that would not save, without
+// a # title or with [[links]] to no zettel
+func checkContent(ctx context.Context, zr repository.ZettelRepository, content string) error {
+	lines := strings.Split(content, "\n")
+	title := strings.TrimSpace(strings.TrimPrefix(lines[0], "# "))
+	if !strings.HasPrefix(lines[0], "# ") || title == "" {
+		return fmt.Errorf("%w: the content has no # title", repository.ErrInvalidZettel)
+	}
+
+	self := slug.Make(title)
+	for _, line := range lines {
+		for _, result := range fs.MatchAllSubstrings("[[", "]]", line) {
+			if result == "" || result == self {
+				continue
+			}
+			if err := zr.Resolve(ctx, model.NewLink(result)); err != nil {
+				return fmt.Errorf("error: failed to resolve [[%s]]: %w", result, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Serve listens on the given address until the context is cancelled
+func Serve(ctx context.Context, zr repository.ZettelRepository, addr string) error {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: NewServer(zr),
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return srv.Shutdown(context.Background())
+	}
+}
+
+// writeRepositoryError maps the errors to http status codes, by their exit
+// code so zet and zet serve agree on them
+func writeRepositoryError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	switch code, _ := exitCode(err); code {
+	case ExitUsage, ExitInvalid:
+		status = http.StatusBadRequest
+	case ExitNotFound:
+		status = http.StatusNotFound
+	case ExitAmbiguous, ExitConflict:
+		status = http.StatusConflict
+	case ExitNotReady:
+		status = http.StatusUnprocessableEntity
+	case ExitBusy:
+		status = http.StatusServiceUnavailable
+	}
+
+	writeError(w, status, err)
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("error: method not allowed"))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, &errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+func TestServer(t *testing.T) {
+	t.Run("create -> save with content -> get -> backlinks", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+		srv := httptest.NewServer(NewServer(zr))
+		defer srv.Close()
+
+		res, err := http.Post(srv.URL+"/zettels", "application/json", strings.NewReader(`{"title": "A title one"}`))
+		require.Equal(t, err, nil, "failed to create zettel")
+		require.Equal(t, res.StatusCode, http.StatusCreated, "status should be 201")
+
+		z1 := &model.Zettel{}
+		json.NewDecoder(res.Body).Decode(z1)
+		res.Body.Close()
+
+		z2 := createZet(t, zr, "A title two")
+
+		body := `{"content": "# A title two\n\nLinked to [[` + z1.Slug + `]]\n"}`
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+"/zettels/"+z2.ID, strings.NewReader(body))
+		res, err = http.DefaultClient.Do(req)
+		require.Equal(t, err, nil, "failed to save zettel")
+		require.Equal(t, res.StatusCode, http.StatusOK, "status should be 200")
+		res.Body.Close()
+
+		res, err = http.Get(srv.URL + "/zettels/" + z2.ID)
+		require.Equal(t, err, nil, "failed to get zettel")
+		require.Equal(t, res.StatusCode, http.StatusOK, "status should be 200")
+
+		var got zettelResponse
+		json.NewDecoder(res.Body).Decode(&got)
+		res.Body.Close()
+
+		assert.Equal(t, strings.Contains(got.Content, "[["+z1.Slug+"]]"), true, "content should be returned")
+		require.Equal(t, len(got.Links), 1, "z2 should have one link")
+		assert.Equal(t, got.Links[0].ID, z1.ID, "z2 should link to z1")
+
+		res, err = http.Get(srv.URL + "/zettels/" + z1.ID + "/backlinks")
+		require.Equal(t, err, nil, "failed to get backlinks")
+
+		var backlinks []*model.Zettel
+		json.NewDecoder(res.Body).Decode(&backlinks)
+		res.Body.Close()
+
+		require.Equal(t, len(backlinks), 1, "z1 should have one backlink")
+		assert.Equal(t, backlinks[0].ID, z2.ID, "z2 should link to z1")
+	})
+
+	t.Run("invalid content leaves the file unchanged", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+		srv := httptest.NewServer(NewServer(zr))
+		defer srv.Close()
+
+		z1 := createZet(t, zr, "A title one")
+		before, err := fs.Read(z1.Path)
+		require.Equal(t, err, nil, "failed to read z1")
+
+		for _, content := range []string{"no title here", "# A title one\n\nLinked to [[a-missing-zettel]]"} {
+			body, _ := json.Marshal(map[string]string{"content": content})
+			req, _ := http.NewRequest(http.MethodPut, srv.URL+"/zettels/"+z1.ID, bytes.NewReader(body))
+			res, err := http.DefaultClient.Do(req)
+			require.Equal(t, err, nil, "failed to save zettel")
+			assert.NotEqual(t, res.StatusCode, http.StatusOK, "invalid content should be refused: "+content)
+			res.Body.Close()
+
+			after, err := fs.Read(z1.Path)
+			require.Equal(t, err, nil, "failed to read z1")
+			assert.Equal(t, after, before, "z1 should not change: "+content)
+		}
+	})
+
+	t.Run("maps repository errors to status codes", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+		srv := httptest.NewServer(NewServer(zr))
+		defer srv.Close()
+
+		res, err := http.Get(srv.URL + "/zettels/does-not-exist")
+		require.Equal(t, err, nil, "failed to get zettel")
+		assert.Equal(t, res.StatusCode, http.StatusNotFound, "status should be 404")
+		res.Body.Close()
+
+		res, err = http.Post(srv.URL+"/zettels", "application/json", strings.NewReader(`{"title": ""}`))
+		require.Equal(t, err, nil, "failed to create zettel")
+		assert.Equal(t, res.StatusCode, http.StatusBadRequest, "status should be 400")
+		res.Body.Close()
+
+		res, err = http.Get(srv.URL + "/search?q=" + url.QueryEscape(`"unbalanced`))
+		require.Equal(t, err, nil, "failed to search")
+		assert.Equal(t, res.StatusCode, http.StatusBadRequest, "invalid queries should be 400")
+		res.Body.Close()
+
+		req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/zettels", nil)
+		res, err = http.DefaultClient.Do(req)
+		require.Equal(t, err, nil, "failed to delete zettels")
+		assert.Equal(t, res.StatusCode, http.StatusMethodNotAllowed, "status should be 405")
+		res.Body.Close()
+
+		cases := []struct {
+			err    error
+			status int
+		}{
+			{fmt.Errorf("%w: /tmp/not-a-zettel.md", repository.ErrInvalidZettel), http.StatusBadRequest},
+			{&LintError{Violations: []*Violation{{Rule: RuleTag}}}, http.StatusUnprocessableEntity},
+			{ErrNotReady, http.StatusUnprocessableEntity},
+			{fmt.Errorf("%w: [[A title]] matches a, b", repository.ErrZettelAmbiguous), http.StatusConflict},
+		}
+		for _, c := range cases {
+			rec := httptest.NewRecorder()
+			writeRepositoryError(rec, c.err)
+			assert.Equal(t, rec.Code, c.status, fmt.Sprintf("wrong status for %v", c.err))
+		}
+	})
+}
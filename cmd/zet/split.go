@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/odas0r/zet/internal/config"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+// LineRange is a range of lines of a zettel, from 1 and inclusive
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// section is a part of a zettel split into a new one, the lines are from 0
+// with the end exclusive
+type section struct {
+	start int
+	end   int
+	title string
+	body  []string
+}
+
+// ParseLineRanges parses ranges like "3-10,12-20", a single line is a range
+// of one line
+func ParseLineRanges(s string) ([]*LineRange, error) {
+	ranges := []*LineRange{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, found := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, usageErrorf("error: invalid line range %q", part)
+		}
+		end := start
+		if found {
+			if end, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+				return nil, usageErrorf("error: invalid line range %q", part)
+			}
+		}
+		if start < 1 || end < start {
+			return nil, usageErrorf("error: invalid line range %q", part)
+		}
+
+		ranges = append(ranges, &LineRange{Start: start, End: end})
+	}
+
+	if len(ranges) == 0 {
+		return nil, usageErrorf("error: no line ranges in %q", s)
+	}
+
+	return ranges, nil
+}
+
+// Split moves parts of the zettel given by its path, id, slug or title into
+// new zettels, and puts a [[link]] to each of them in their place. Without
+// ranges there is a new zettel per ## section, titled by its heading.
+// Otherwise there is one per range of lines, titled by its first line. With
+// folgezettel ids the new zettels are children of the split one.
+func Split(zr repository.ZettelRepository, arg string, ranges []*LineRange) ([]*model.Zettel, error) {
+	cfg := zr.Config()
+
+	zet, err := findZettel(zr, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := fs.Read(zet.Path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(content, "\n")
+
+	var sections []*section
+	if len(ranges) == 0 {
+		sections = headingSections(lines)
+		if len(sections) == 0 {
+			return nil, fmt.Errorf("error: %s has no ## sections to split", zet.Slug)
+		}
+	} else {
+		if sections, err = rangeSections(lines, ranges); err != nil {
+			return nil, err
+		}
+	}
+
+	parent := ""
+	if cfg.IDFormat == config.IDFormatFolgezettel {
+		parent = zet.ID
+	}
+
+	zettels := []*model.Zettel{}
+	// the new zettels are removed when a step fails, so their content is not
+	// left duplicated
+	discard := func(err error) ([]*model.Zettel, error) {
+		ctx := context.Background()
+		for _, created := range zettels {
+			if removeErr := os.Remove(created.Path); removeErr != nil && !os.IsNotExist(removeErr) {
+				err = withUndo(err, removeErr)
+			}
+			err = withUndo(err, zr.Remove(ctx, created), zr.RemoveEvents(ctx, created))
+		}
+		return nil, err
+	}
+
+	for _, s := range sections {
+		created, err := New(zr, s.title, parent)
+		if err != nil {
+			return discard(err)
+		}
+		zettels = append(zettels, created)
+
+		content := "# " + s.title + "\n"
+		if len(s.body) > 0 {
+			content += "\n" + strings.Join(s.body, "\n") + "\n"
+		}
+		if err := fs.WriteAtomic(created.Path, content); err != nil {
+			return discard(err)
+		}
+
+		saved, err := Save(zr, created.Path)
+		if err != nil {
+			return discard(err)
+		}
+		zettels[len(zettels)-1] = saved
+	}
+
+	// from the last section, so the lines of the others stay in place
+	for i := len(sections) - 1; i >= 0; i-- {
+		s := sections[i]
+		link := "[[" + zettels[i].Slug + "]]"
+		lines = append(lines[:s.start], append([]string{link}, lines[s.end:]...)...)
+	}
+
+	if err := fs.WriteAtomic(zet.Path, strings.Join(lines, "\n")); err != nil {
+		return discard(err)
+	}
+
+	if _, err := Save(zr, zet.Path); err != nil {
+		// back to the original, indexed again without the links
+		if restoreErr := fs.WriteAtomic(zet.Path, content); restoreErr != nil {
+			err = withUndo(err, restoreErr)
+		} else if _, saveErr := Save(zr, zet.Path); saveErr != nil {
+			err = withUndo(err, saveErr)
+		}
+		return discard(err)
+	}
+
+	return zettels, nil
+}
+
+// headingSections returns the ## sections of the lines, outside of fenced
+// code blocks. A section ends at the next heading of level one or two, the
+// #tags closing the zettel are not part of the last one.
+func headingSections(lines []string) []*section {
+	sections := []*section{}
+
+	var current *section
+	closeSection := func(end int) {
+		if current == nil {
+			return
+		}
+		current.end = end
+		sections = append(sections, current)
+		current = nil
+	}
+
+	fenced := false
+	for i, line := range lines {
+		if i == 0 {
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			fenced = !fenced
+		}
+		if fenced {
+			continue
+		}
+
+		if strings.HasPrefix(line, "## ") || strings.HasPrefix(line, "# ") {
+			closeSection(i)
+		}
+		if strings.HasPrefix(line, "## ") {
+			current = &section{start: i, title: strings.TrimSpace(strings.TrimPrefix(line, "## "))}
+		}
+	}
+
+	end := len(lines)
+	if current != nil {
+		for end > current.start+1 {
+			trimmed := strings.TrimSpace(lines[end-1])
+			if trimmed != "" && !tagLineRe.MatchString(trimmed) {
+				break
+			}
+			end--
+		}
+	}
+	closeSection(end)
+
+	for _, s := range sections {
+		// the blank lines between the sections stay in the zettel
+		for s.end > s.start+1 && strings.TrimSpace(lines[s.end-1]) == "" {
+			s.end--
+		}
+		s.body = trimBlankLines(lines[s.start+1 : s.end])
+	}
+
+	return sections
+}
+
+// rangeSections returns a section per range, titled by its first line
+// without the heading marks. The title of the zettel cannot be split.
+func rangeSections(lines []string, ranges []*LineRange) ([]*section, error) {
+	sorted := make([]*LineRange, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	sections := []*section{}
+	for i, r := range sorted {
+		if r.Start < 2 || r.End > len(lines) {
+			return nil, fmt.Errorf("error: line range %d-%d is out of lines 2-%d", r.Start, r.End, len(lines))
+		}
+		if i > 0 && r.Start <= sorted[i-1].End {
+			return nil, fmt.Errorf("error: line ranges %d-%d and %d-%d overlap", sorted[i-1].Start, sorted[i-1].End, r.Start, r.End)
+		}
+
+		title := strings.TrimSpace(strings.TrimLeft(lines[r.Start-1], "#"))
+		if title == "" {
+			return nil, fmt.Errorf("error: line %d is empty, the first line of a range is the title", r.Start)
+		}
+
+		sections = append(sections, &section{
+			start: r.Start - 1,
+			end:   r.End,
+			title: title,
+			body:  trimBlankLines(lines[r.Start:r.End]),
+		})
+	}
+
+	return sections, nil
+}
+
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
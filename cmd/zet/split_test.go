@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+func TestSplit(t *testing.T) {
+	t.Run("split by heading -> new zettels linked in place", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+		ctx := context.Background()
+
+		z1 := createZet(t, zr, "A title one")
+		z1.Content = "# A title one\n\nAn intro line\n\n## A section one\n\nA first line\n\n### A subsection\n\nA nested line\n\n## A section two\n\nA second line\n\n#tag\n"
+		err := z1.Write()
+		require.Equal(t, err, nil, "failed to write z1")
+		saveZet(t, zr, z1)
+
+		zettels, err := Split(zr, z1.Slug, nil)
+		require.Equal(t, err, nil, "failed to split z1")
+		require.Equal(t, len(zettels), 2, "a zettel per section should be created")
+		assert.Equal(t, zettels[0].Title, "A section one", "the heading should be the title")
+		assert.Equal(t, zettels[0].Content, "# A section one\n\nA first line\n\n### A subsection\n\nA nested line", "the subsections should be kept")
+		assert.Equal(t, zettels[1].Content, "# A section two\n\nA second line", "the closing tags should stay in z1")
+
+		content, err := fs.Read(z1.Path)
+		require.Equal(t, err, nil, "failed to read z1")
+		expected := "# A title one\n\nAn intro line\n\n[[" + zettels[0].Slug + "]]\n\n[[" + zettels[1].Slug + "]]\n\n#tag\n"
+		assert.Equal(t, content, expected, "the sections should be replaced by links")
+
+		split := &model.Zettel{ID: z1.ID}
+		err = zr.Get(ctx, split)
+		require.Equal(t, err, nil, "failed to get z1")
+		assert.Equal(t, len(split.Links), 2, "the links should be indexed")
+
+		created := &model.Zettel{ID: zettels[1].ID}
+		err = zr.Get(ctx, created)
+		require.Equal(t, err, nil, "the new zettels should be indexed")
+
+		_, err = Split(zr, zettels[1].Slug, nil)
+		assert.NotEqual(t, err, nil, "zettels without sections should not be split")
+	})
+
+	t.Run("split by line ranges", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z1.Content = "# A title one\n\nA first idea\nA first detail\n\nA second idea\nA second detail\n"
+		err := z1.Write()
+		require.Equal(t, err, nil, "failed to write z1")
+		saveZet(t, zr, z1)
+
+		_, err = ParseLineRanges("3-x")
+		assert.NotEqual(t, err, nil, "invalid ranges should be refused")
+		_, err = ParseLineRanges(" ")
+		assert.NotEqual(t, err, nil, "empty ranges should be refused")
+
+		ranges, err := ParseLineRanges("3-4,6-7")
+		require.Equal(t, err, nil, "failed to parse the ranges")
+
+		_, err = Split(zr, z1.ID, []*LineRange{{Start: 3, End: 6}, {Start: 6, End: 7}})
+		assert.NotEqual(t, err, nil, "overlapping ranges should be refused")
+		_, err = Split(zr, z1.ID, []*LineRange{{Start: 1, End: 2}})
+		assert.NotEqual(t, err, nil, "the title should not be split")
+
+		zettels, err := Split(zr, z1.ID, ranges)
+		require.Equal(t, err, nil, "failed to split z1")
+		require.Equal(t, len(zettels), 2, "a zettel per range should be created")
+		assert.Equal(t, zettels[1].Title, "A second idea", "the first line should be the title")
+		assert.Equal(t, zettels[1].Content, "# A second idea\n\nA second detail", "the other lines should be the body")
+
+		content, err := fs.Read(z1.Path)
+		require.Equal(t, err, nil, "failed to read z1")
+		expected := "# A title one\n\n[[" + zettels[0].Slug + "]]\n\n[[" + zettels[1].Slug + "]]\n"
+		assert.Equal(t, content, expected, "the ranges should be replaced by links")
+	})
+
+	t.Run("a failed split leaves nothing behind", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, cfg := startup(t)
+		ctx := context.Background()
+
+		z1 := createZet(t, zr, "A title one")
+		original := "# A title one\n\n## A section one\n\nA first line\n\n## A section two\n\nLinked to [[A missing zettel]]\n"
+		// saving refuses broken links, the file is split as it is
+		err := fs.WriteAtomic(z1.Path, original)
+		require.Equal(t, err, nil, "failed to write z1")
+
+		// the history of a zettel removed outside of zet, not the split's to clean
+		err = zr.InsertEvent(ctx, &model.Zettel{ID: "19990101000000000"}, model.EventSaved)
+		require.Equal(t, err, nil, "failed to insert the orphaned event")
+
+		_, err = Split(zr, z1.ID, nil)
+		assert.NotEqual(t, err, nil, "the broken link should fail the split")
+
+		content, err := fs.Read(z1.Path)
+		require.Equal(t, err, nil, "failed to read z1")
+		assert.Equal(t, content, original, "z1 should not change")
+
+		zettels, err := zr.ListAll(ctx)
+		require.Equal(t, err, nil, "failed to list the zettels")
+		assert.Equal(t, len(zettels), 1, "the new zettels should be removed")
+		assert.Equal(t, len(fs.List(cfg.FleetRoot)), 1, "the new files should be removed")
+
+		orphaned, err := zr.OrphanedHistory(ctx)
+		require.Equal(t, err, nil, "failed to query the orphaned history")
+		assert.Equal(t, strings.Join(orphaned, " "), "19990101000000000", "only the events of the new zettels should be removed")
+	})
+}
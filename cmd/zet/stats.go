@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+)
+
+// Periods of the statistics
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+const dateLayout = "2006-01-02"
+
+type ZettelStats struct {
+	Zettels   int `json:"zettels"`
+	Fleet     int `json:"fleet"`
+	Permanent int `json:"permanent"`
+	Words     int `json:"words"`
+	// share of the zettels that made it to permanent
+	ConversionRate float64 `json:"conversionRate"`
+	// median days between the creation and the promotion of a zettel
+	MedianDaysInFleet float64 `json:"medianDaysInFleet"`
+	// consecutive days with activity, up to today or yesterday
+	CurrentStreak int `json:"currentStreak"`
+	LongestStreak int `json:"longestStreak"`
+
+	Period  string         `json:"period"`
+	Periods []*StatsPeriod `json:"periods"`
+	// every day since the start of the first week, for the heatmap
+	Days []*StatsDay `json:"days"`
+}
+
+type StatsPeriod struct {
+	// first day of the period, like 2024-07-15
+	Start    string `json:"start"`
+	Created  int    `json:"created"`
+	Promoted int    `json:"promoted"`
+	// words of the zettels created in the period, as they are now
+	Words int `json:"words"`
+}
+
+type StatsDay struct {
+	Date string `json:"date"`
+	// zettels created, saved and promoted on the day
+	Activity int `json:"activity"`
+}
+
+// Stats returns the activity of the zettelkasten grouped by period, for the
+// given duration until now. The totals, conversion and streaks are of all
+// time.
+func Stats(zr repository.ZettelRepository, period string, since time.Duration) (*ZettelStats, error) {
+	ctx := context.Background()
+
+	zettels, err := zr.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := zr.Events(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return computeStats(zettels, events, period, since, time.Now())
+}
+
+func computeStats(zettels []*model.Zettel, events []*model.Event, period string, since time.Duration, now time.Time) (*ZettelStats, error) {
+	if period != PeriodDay && period != PeriodWeek && period != PeriodMonth {
+		return nil, usageErrorf("error: invalid period %q, expected day, week or month", period)
+	}
+
+	now = now.Local()
+	stats := &ZettelStats{
+		Period:  period,
+		Periods: []*StatsPeriod{},
+		Days:    []*StatsDay{},
+	}
+
+	periods := make(map[string]*StatsPeriod)
+	first := periodStart(now.Add(-since), period)
+	for start := first; !start.After(now); start = nextPeriod(start, period) {
+		p := &StatsPeriod{Start: start.Format(dateLayout)}
+		stats.Periods = append(stats.Periods, p)
+		periods[p.Start] = p
+	}
+
+	activity := make(map[string]int)
+	created := make(map[string]time.Time, len(zettels))
+
+	for _, zet := range zettels {
+		words := countWords(zet.Content)
+
+		stats.Zettels++
+		stats.Words += words
+		if zet.Type == "permanent" {
+			stats.Permanent++
+		} else {
+			stats.Fleet++
+		}
+
+		t := zet.CreatedAt.T.Local()
+		created[zet.ID] = t
+		activity[t.Format(dateLayout)]++
+
+		if p, ok := periods[periodStart(t, period).Format(dateLayout)]; ok {
+			p.Created++
+			p.Words += words
+		}
+	}
+
+	if stats.Zettels > 0 {
+		stats.ConversionRate = float64(stats.Permanent) / float64(stats.Zettels)
+	}
+
+	fleetDays := make(map[string]float64)
+
+	for _, e := range events {
+		t := e.CreatedAt.T.Local()
+
+		switch e.Kind {
+		case model.EventSaved:
+			activity[t.Format(dateLayout)]++
+		case model.EventPromoted:
+			activity[t.Format(dateLayout)]++
+
+			if p, ok := periods[periodStart(t, period).Format(dateLayout)]; ok {
+				p.Promoted++
+			}
+
+			// the events are newest first, the first promotion is the last one
+			// seen
+			if c, ok := created[e.ZettelID]; ok {
+				fleetDays[e.ZettelID] = t.Sub(c).Hours() / 24
+			}
+		}
+	}
+
+	days := make([]float64, 0, len(fleetDays))
+	for _, d := range fleetDays {
+		days = append(days, d)
+	}
+	stats.MedianDaysInFleet = math.Round(median(days)*10) / 10
+	stats.CurrentStreak, stats.LongestStreak = streaks(activity, now)
+
+	// whole weeks, so the heatmap starts on a monday
+	for day := periodStart(now.Add(-since), PeriodWeek); !day.After(now); day = day.AddDate(0, 0, 1) {
+		date := day.Format(dateLayout)
+		stats.Days = append(stats.Days, &StatsDay{Date: date, Activity: activity[date]})
+	}
+
+	return stats, nil
+}
+
+// WriteStats writes the statistics as text, with a heatmap of the activity
+// of the last weeks
+func WriteStats(w io.Writer, stats *ZettelStats) {
+	fmt.Fprintf(w, "zettels     %d (%d fleet, %d permanent)\n", stats.Zettels, stats.Fleet, stats.Permanent)
+	fmt.Fprintf(w, "words       %d\n", stats.Words)
+	fmt.Fprintf(w, "conversion  %.1f%%\n", stats.ConversionRate*100)
+	fmt.Fprintf(w, "in fleet    %.1f days (median)\n", stats.MedianDaysInFleet)
+	fmt.Fprintf(w, "streak      %d days (longest %d)\n\n", stats.CurrentStreak, stats.LongestStreak)
+
+	fmt.Fprintf(w, "%-10s  %7s  %8s  %7s\n", stats.Period, "created", "promoted", "words")
+	for _, p := range stats.Periods {
+		fmt.Fprintf(w, "%-10s  %7d  %8d  %7d\n", p.Start, p.Created, p.Promoted, p.Words)
+	}
+
+	if len(stats.Days) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+
+	most := 0
+	for _, d := range stats.Days {
+		if d.Activity > most {
+			most = d.Activity
+		}
+	}
+
+	weeks := (len(stats.Days) + 6) / 7
+
+	// the month above its first week
+	header := []rune(strings.Repeat(" ", weeks+3))
+	month, free := "", 0
+	for week := 0; week < weeks; week++ {
+		t, err := time.Parse(dateLayout, stats.Days[week*7].Date)
+		if err != nil {
+			continue
+		}
+		m := t.Format("Jan")
+		if m == month {
+			continue
+		}
+		month = m
+
+		// skipped when it would overlap the previous month
+		if week >= free {
+			copy(header[week:], []rune(m))
+			free = week + 4
+		}
+	}
+	fmt.Fprintf(w, "    %s\n", strings.TrimRight(string(header), " "))
+
+	levels := []rune("░▒▓█")
+	for weekday := 0; weekday < 7; weekday++ {
+		label := "   "
+		if weekday%2 == 0 {
+			label = []string{"Mon", "Wed", "Fri", "Sun"}[weekday/2]
+		}
+
+		var b strings.Builder
+		for week := 0; week < weeks; week++ {
+			i := week*7 + weekday
+			if i >= len(stats.Days) {
+				break
+			}
+
+			a := stats.Days[i].Activity
+			if a == 0 {
+				b.WriteRune('·')
+				continue
+			}
+			b.WriteRune(levels[(a*len(levels)-1)/most])
+		}
+
+		fmt.Fprintf(w, "%s %s\n", label, b.String())
+	}
+}
+
+// periodStart returns the first day of the period the time is in, weeks
+// start on monday
+func periodStart(t time.Time, period string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	switch period {
+	case PeriodWeek:
+		// sunday is 0
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case PeriodMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+
+	return day
+}
+
+func nextPeriod(start time.Time, period string) time.Time {
+	switch period {
+	case PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		return start.AddDate(0, 1, 0)
+	}
+
+	return start.AddDate(0, 0, 1)
+}
+
+// streaks returns the days in a row with activity up to today, or yesterday
+// when nothing was done today yet, and the longest run of them
+func streaks(activity map[string]int, now time.Time) (int, int) {
+	var dates []string
+	for date, n := range activity {
+		if n > 0 {
+			dates = append(dates, date)
+		}
+	}
+	sort.Strings(dates)
+
+	longest, run := 0, 0
+	var prev time.Time
+	for _, date := range dates {
+		t, err := time.ParseInLocation(dateLayout, date, now.Location())
+		if err != nil {
+			continue
+		}
+
+		if run > 0 && prev.AddDate(0, 0, 1).Equal(t) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+		prev = t
+	}
+
+	current := 0
+	day := periodStart(now, PeriodDay)
+	if activity[day.Format(dateLayout)] == 0 {
+		day = day.AddDate(0, 0, -1)
+	}
+	for activity[day.Format(dateLayout)] > 0 {
+		current++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	return current, longest
+}
+
+// countWords counts the words of the content, leaving out the markdown
+// marks like # and -
+func countWords(content string) int {
+	n := 0
+	for _, field := range strings.Fields(content) {
+		if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+
+	return sorted[mid]
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/model"
+)
+
+func TestStats(t *testing.T) {
+	t.Run("zettels and events -> periods, conversion and streaks", func(t *testing.T) {
+		// a wednesday
+		now := time.Date(2024, 7, 17, 12, 0, 0, 0, time.Local)
+		day := func(d int) model.Time {
+			return model.Time{T: now.AddDate(0, 0, d)}
+		}
+
+		zettels := []*model.Zettel{
+			{ID: "1", Type: "permanent", Content: "# One\n\nfour words here", CreatedAt: day(-10)},
+			{ID: "2", Type: "permanent", Content: "# Two\n\ntwo words", CreatedAt: day(-2)},
+			{ID: "3", Type: "fleet", Content: "# Three", CreatedAt: day(-1)},
+		}
+
+		// newest first, like the history
+		events := []*model.Event{
+			{ZettelID: "3", Kind: model.EventSaved, CreatedAt: day(0)},
+			{ZettelID: "2", Kind: model.EventPromoted, CreatedAt: day(0)},
+			{ZettelID: "1", Kind: model.EventPromoted, CreatedAt: day(-5)},
+			{ZettelID: "1", Kind: model.EventPromoted, CreatedAt: day(-6)},
+			{ZettelID: "1", Kind: model.EventOpened, CreatedAt: day(-4)},
+		}
+
+		stats, err := computeStats(zettels, events, PeriodWeek, 14*24*time.Hour, now)
+		require.Equal(t, err, nil, "failed to compute the statistics")
+
+		assert.Equal(t, stats.Zettels, 3, "every zettel should be counted")
+		assert.Equal(t, stats.Words, 8, "every word should be counted")
+		assert.Equal(t, int(stats.ConversionRate*100), 66, "two of three zettels are permanent")
+		assert.Equal(t, stats.MedianDaysInFleet, 3.0, "median of 4 and 2 days, from the first promotion")
+		assert.Equal(t, stats.CurrentStreak, 3, "today, yesterday and the day before")
+		assert.Equal(t, stats.LongestStreak, 3, "the current streak is the longest")
+
+		require.Equal(t, len(stats.Periods), 3, "three weeks should be listed")
+		assert.Equal(t, stats.Periods[0].Start, "2024-07-01", "weeks should start on monday")
+		assert.Equal(t, stats.Periods[0].Created, 1, "z1 was created two weeks ago")
+		assert.Equal(t, stats.Periods[1].Promoted, 2, "z1 promotions were last week")
+		assert.Equal(t, stats.Periods[2].Created, 2, "z2 and z3 were created this week")
+		assert.Equal(t, stats.Periods[2].Words, 4, "words of z2 and z3, without the marks")
+
+		require.Equal(t, len(stats.Days), 17, "days should start on the first monday")
+		assert.Equal(t, stats.Days[16].Activity, 2, "today has a save and a promotion")
+
+		_, err = computeStats(zettels, events, "year", 0, now)
+		assert.NotEqual(t, err, nil, "unknown periods should be refused")
+
+		var out bytes.Buffer
+		WriteStats(&out, stats)
+		assert.Equal(t, strings.Contains(out.String(), "streak      3 days (longest 3)"), true, "streaks should be written")
+		assert.Equal(t, strings.Contains(out.String(), "Mon "), true, "heatmap should be written")
+	})
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/repository"
+)
+
+// Orders of the backlog
+const (
+	// most recently updated first
+	SortUpdated = "updated"
+	// oldest first
+	SortAge = "age"
+	// least recently updated first
+	SortStale = "stale"
+	// fewest links first, the orphans need the most attention
+	SortLinks = "links"
+)
+
+// Actions of the triage
+const (
+	TriagePromoted = "promoted"
+	TriageArchived = "archived"
+	TriageMerged   = "merged"
+	TriageRemoved  = "removed"
+	TriageSkipped  = "skipped"
+)
+
+// BacklogItem is a fleet zettel with what the triage needs to decide on it
+type BacklogItem struct {
+	*model.Zettel
+
+	// days since the creation and since the last update
+	Age       int `json:"age"`
+	Staleness int `json:"staleness"`
+	// links from and to the zettel
+	LinkCount int `json:"links"`
+	// older than the threshold of the triage
+	Stale bool `json:"stale"`
+}
+
+// BacklogFilter orders the backlog and flags its stale zettels, the zero
+// value keeps the order of the repository and flags none
+type BacklogFilter struct {
+	// updated, age, stale or links
+	Sort string
+	// zettels older than this are stale
+	OlderThan time.Duration
+	// only the stale zettels
+	Stale bool
+}
+
+type TriageAction struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Action string `json:"action"`
+	// slug of the zettel it was merged into
+	Into string `json:"into,omitempty"`
+}
+
+// Backlog returns the fleet zettels with their age and links, ordered and
+// flagged by the filter
+func Backlog(zr repository.ZettelRepository, filter *BacklogFilter) ([]*BacklogItem, error) {
+	if filter == nil {
+		filter = &BacklogFilter{}
+	}
+
+	ctx := context.Background()
+
+	zettels, err := zr.ListFleet(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := zr.ListLinks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, link := range links {
+		counts[link.From]++
+		counts[link.To]++
+	}
+
+	now := time.Now()
+	items := []*BacklogItem{}
+	for _, zet := range zettels {
+		item := &BacklogItem{
+			Zettel:    zet,
+			Age:       int(now.Sub(zet.CreatedAt.T).Hours() / 24),
+			Staleness: int(now.Sub(zet.UpdatedAt.T).Hours() / 24),
+			LinkCount: counts[zet.ID],
+			Stale:     filter.OlderThan > 0 && now.Sub(zet.CreatedAt.T) > filter.OlderThan,
+		}
+		if filter.Stale && !item.Stale {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	var less func(a, b *BacklogItem) bool
+	switch filter.Sort {
+	case "", SortUpdated:
+		// already ordered by the repository
+		return items, nil
+	case SortAge:
+		less = func(a, b *BacklogItem) bool {
+			if a.CreatedAt.T.Equal(b.CreatedAt.T) {
+				return a.ID < b.ID
+			}
+			return a.CreatedAt.T.Before(b.CreatedAt.T)
+		}
+	case SortStale:
+		less = func(a, b *BacklogItem) bool { return a.UpdatedAt.T.Before(b.UpdatedAt.T) }
+	case SortLinks:
+		less = func(a, b *BacklogItem) bool { return a.LinkCount < b.LinkCount }
+	default:
+		return nil, usageErrorf("error: invalid sort %q, expected updated, age, stale or links", filter.Sort)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return less(items[i], items[j]) })
+
+	return items, nil
+}
+
+// Triage walks the backlog asking what to do with each zettel: promote it,
+// archive it, merge it into another zettel, delete it, edit it or skip it.
+// The prompts are written to out and the answers read from in, it stops at
+// the end of the backlog, on quit or when in is closed.
+func Triage(zr repository.ZettelRepository, items []*BacklogItem, in io.Reader, out io.Writer, edit func(path string) error) ([]*TriageAction, error) {
+	scanner := bufio.NewScanner(in)
+	ask := func(prompt string) (string, bool) {
+		fmt.Fprint(out, prompt)
+		if !scanner.Scan() {
+			return "", false
+		}
+		return strings.TrimSpace(scanner.Text()), true
+	}
+
+	actions := []*TriageAction{}
+
+	for i, item := range items {
+		zet := item.Zettel
+		action := &TriageAction{ID: zet.ID, Title: zet.Title}
+
+		for action.Action == "" {
+			fmt.Fprintf(out, "\n[%d/%d] %s (%s)\n", i+1, len(items), zet.Title, zet.Slug)
+			fmt.Fprintf(out, "%d days old, untouched for %d days, %d links", item.Age, item.Staleness, item.LinkCount)
+			if item.Stale {
+				fmt.Fprint(out, ", stale")
+			}
+			fmt.Fprintln(out)
+			if p := zet.FirstParagraph(); p != "" {
+				fmt.Fprintf(out, "%s\n", p)
+			}
+
+			answer, ok := ask("(p)romote (a)rchive (m)erge into (d)elete (e)dit (s)kip (q)uit? ")
+			if !ok {
+				return actions, scanner.Err()
+			}
+
+			var err error
+			switch answer {
+			case "p", "promote":
+				_, err = Permanent(zr, zet.Path, false)
+				if err == nil {
+					action.Action = TriagePromoted
+				}
+			case "a", "archive":
+				_, err = Archive(zr, zet.Path)
+				if err == nil {
+					action.Action = TriageArchived
+				}
+			case "m", "merge":
+				target, ok := ask("merge into (slug, title or id)? ")
+				if !ok {
+					return actions, scanner.Err()
+				}
+				if target == "" {
+					continue
+				}
+
+				var dst *model.Zettel
+				dst, err = findZettel(zr, target)
+				if err == nil {
+					_, err = MergeInto(zr, zet, dst)
+				}
+				if err == nil {
+					action.Action = TriageMerged
+					action.Into = dst.Slug
+				}
+			case "d", "delete":
+				_, err = Remove(zr, zet.Path)
+				if err == nil {
+					action.Action = TriageRemoved
+				}
+			case "e", "edit":
+				if err = edit(zet.Path); err == nil {
+					zet, err = Save(zr, zet.Path)
+				}
+				if err == nil {
+					item.Zettel = zet
+				}
+			case "s", "skip", "":
+				action.Action = TriageSkipped
+			case "q", "quit":
+				return actions, nil
+			default:
+				fmt.Fprintf(out, "unknown answer %q\n", answer)
+			}
+
+			if err != nil {
+				fmt.Fprintf(out, "%v\n", err)
+			}
+		}
+
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
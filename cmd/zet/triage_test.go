@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+func TestTriage(t *testing.T) {
+	t.Run("backlog -> sort -> flag stale", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z2 := createZet(t, zr, "A title two")
+		z2.WriteLine(fmt.Sprintf("Linked to [[%s]]", z1.Slug))
+		saveZet(t, zr, z2)
+		z3 := createZet(t, zr, "A title three")
+
+		items, err := Backlog(zr, &BacklogFilter{Sort: SortAge})
+		require.Equal(t, err, nil, "failed to query the backlog")
+		require.Equal(t, len(items), 3, "every fleet zettel should be listed")
+		assert.Equal(t, items[0].ID, z1.ID, "oldest should be first")
+		assert.Equal(t, items[0].LinkCount, 1, "backlinks should be counted")
+		assert.Equal(t, items[0].Stale, false, "nothing is stale without a threshold")
+
+		items, err = Backlog(zr, &BacklogFilter{Sort: SortLinks})
+		require.Equal(t, err, nil, "failed to query the backlog")
+		assert.Equal(t, items[0].ID, z3.ID, "orphans should be first")
+
+		items, err = Backlog(zr, &BacklogFilter{OlderThan: time.Nanosecond, Stale: true})
+		require.Equal(t, err, nil, "failed to query the backlog")
+		assert.Equal(t, len(items), 3, "every zettel should be stale")
+
+		items, err = Backlog(zr, &BacklogFilter{OlderThan: time.Hour, Stale: true})
+		require.Equal(t, err, nil, "failed to query the backlog")
+		assert.Equal(t, len(items), 0, "new zettels should not be stale")
+
+		_, err = Backlog(zr, &BacklogFilter{Sort: "size"})
+		assert.NotEqual(t, err, nil, "unknown sorts should be refused")
+	})
+
+	t.Run("promote -> archive -> merge -> delete -> edit -> skip", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z2 := createZet(t, zr, "A title two")
+		z3 := createZet(t, zr, "A title three")
+		z3.WriteLine("A merged line")
+		saveZet(t, zr, z3)
+		z4 := createZet(t, zr, "A title four")
+		z4.WriteLine(fmt.Sprintf("Linked to [[%s]]", z3.Slug))
+		saveZet(t, zr, z4)
+		z5 := createZet(t, zr, "A title five")
+		z6 := createZet(t, zr, "A title six")
+
+		items, err := Backlog(zr, &BacklogFilter{Sort: SortAge})
+		require.Equal(t, err, nil, "failed to query the backlog")
+		require.Equal(t, len(items), 6, "every fleet zettel should be listed")
+
+		edited := ""
+		edit := func(path string) error {
+			edited = path
+			return fs.WriteAtomic(path, "# A title six\n\nAn edited line\n")
+		}
+
+		in := strings.NewReader(strings.Join([]string{
+			"p",
+			"a",
+			"m", "missing-zettel", "m", z4.Slug,
+			"x", "s",
+			"d",
+			"e", "s",
+		}, "\n"))
+		var out strings.Builder
+
+		actions, err := Triage(zr, items, in, &out, edit)
+		require.Equal(t, err, nil, "failed to triage")
+		require.Equal(t, len(actions), 6, "every zettel should be reported")
+		assert.Equal(t, actions[0].Action, TriagePromoted, "z1 should be promoted")
+		assert.Equal(t, actions[1].Action, TriageArchived, "z2 should be archived")
+		assert.Equal(t, actions[2].Action, TriageMerged, "z3 should be merged")
+		assert.Equal(t, actions[2].Into, z4.Slug, "z3 should be merged into z4")
+		assert.Equal(t, actions[3].Action, TriageSkipped, "unknown answers should ask again")
+		assert.Equal(t, actions[4].Action, TriageRemoved, "z5 should be removed")
+		assert.Equal(t, actions[5].Action, TriageSkipped, "z6 should be skipped after the edit")
+		assert.Equal(t, strings.Contains(out.String(), "zettel not found"), true, "merge errors should be reported")
+		assert.Equal(t, edited, z6.Path, "z6 should be edited")
+
+		ctx := context.Background()
+
+		promoted := &model.Zettel{ID: z1.ID}
+		err = zr.Get(ctx, promoted)
+		require.Equal(t, err, nil, "failed to get z1")
+		assert.Equal(t, promoted.Type, "permanent", "z1 should be permanent")
+
+		archived := &model.Zettel{ID: z2.ID}
+		err = zr.Get(ctx, archived)
+		require.Equal(t, err, nil, "failed to get z2")
+		assert.Equal(t, archived.Type, "archive", "z2 should be archived")
+		assert.Equal(t, fs.Exists(archived.Path), true, "z2 should be in the archive")
+
+		merged := &model.Zettel{ID: z4.ID}
+		err = zr.Get(ctx, merged)
+		require.Equal(t, err, nil, "failed to get z4")
+		assert.Equal(t, strings.Contains(merged.Content, "## A title three\n\nA merged line"), true, "z3 body should be under its heading")
+		assert.Equal(t, strings.Contains(merged.Content, "[["+z3.Slug+"]]"), false, "z4 should not link to z3")
+
+		err = zr.Get(ctx, &model.Zettel{ID: z3.ID})
+		assert.NotEqual(t, err, nil, "z3 should be removed")
+
+		err = zr.Get(ctx, &model.Zettel{ID: z5.ID})
+		assert.NotEqual(t, err, nil, "z5 should be removed")
+
+		edits := &model.Zettel{ID: z6.ID}
+		err = zr.Get(ctx, edits)
+		require.Equal(t, err, nil, "failed to get z6")
+		assert.Equal(t, strings.Contains(edits.Content, "An edited line"), true, "z6 edit should be saved")
+
+		items, err = Backlog(zr, nil)
+		require.Equal(t, err, nil, "failed to query the backlog")
+		assert.Equal(t, len(items), 2, "only z4 and z6 should be left in the backlog")
+	})
+}
@@ -2,23 +2,41 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/odas0r/zet/internal/model"
 	"github.com/odas0r/zet/internal/repository"
 	"github.com/odas0r/zet/pkg/fs"
 )
 
-func New(zr repository.ZettelRepository, title string) (*model.Zettel, error) {
+// New creates a new zettel, the parent is only used by the folgezettel id
+// format to branch off an existing zettel.
+func New(zr repository.ZettelRepository, title string, parent string) (*model.Zettel, error) {
 	zet := &model.Zettel{
 		Title: title,
 	}
 
+	if parent != "" {
+		id, err := zr.NextID(context.Background(), parent)
+		if err != nil {
+			return nil, err
+		}
+		zet.ID = id
+	}
+
 	if err := zr.Save(context.Background(), zet); err != nil {
 		return nil, err
 	}
 
-	if err := fs.Write(zet.Path, zet.Content); err != nil {
+	// never overwrite an existing note
+	if err := fs.CreateExclusive(zet.Path, zet.Content); err != nil {
+		return nil, err
+	}
+
+	if err := zr.InsertEvent(context.Background(), zet, model.EventCreated); err != nil {
 		return nil, err
 	}
 
@@ -39,25 +57,98 @@ func Remove(zr repository.ZettelRepository, path string) (*model.Zettel, error){
 		return nil, err
 	}
 
-	if err := zr.Remove(context.Background(), zet); err != nil {
+	// Move the file to the trash first, it fails if there's already a file
+	// there, see zr.Trash for the path
+	trashPath := zr.Config().TrashRoot + "/" + zet.ID + ".md"
+	moved := fs.Exists(zet.Path)
+	if moved {
+		if err := fs.Move(zet.Path, trashPath); err != nil {
+			return nil, err
+		}
+	}
+
+	// keep the zettel in the trash, so it can be restored
+	if _, err := zr.Trash(context.Background(), zet); err != nil {
+		// put the file back, so the database and the filesystem agree
+		if moved {
+			if err := fs.Move(trashPath, zet.Path); err != nil {
+				log.Printf("warning: failed to move %s back to %s: %v\n", trashPath, zet.Path, err)
+			}
+		}
+		return nil, err
+	}
+
+	if err := zr.InsertEvent(context.Background(), zet, model.EventRemoved); err != nil {
 		return nil, err
 	}
 
-	if fs.Exists(zet.Path) {
-		if err := fs.Remove(zet.Path); err != nil {
+	return zet, nil
+}
+
+func TrashList(zr repository.ZettelRepository) ([]*model.Trash, error) {
+	return zr.ListTrash(context.Background())
+}
+
+// Restore brings back a zettel from the trash, with its links.
+func Restore(zr repository.ZettelRepository, id string) (*model.Trash, error) {
+	trash, err := zr.Restore(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if fs.Exists(trash.TrashPath) {
+		if err := fs.Move(trash.TrashPath, trash.Path); err != nil {
 			return nil, err
 		}
 	}
 
-	return zet, nil
+	return trash, nil
 }
 
-func History(zr repository.ZettelRepository) ([]*model.Zettel, error) {
-	return zr.History(context.Background())
+// EmptyTrash permanently removes the zettels that are in the trash for longer
+// than the given duration.
+func EmptyTrash(zr repository.ZettelRepository, olderThan time.Duration) ([]*model.Trash, error) {
+	trash, err := zr.EmptyTrash(context.Background(), time.Now().Add(-olderThan))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range trash {
+		if fs.Exists(t.TrashPath) {
+			if err := fs.Remove(t.TrashPath); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return trash, nil
+}
+
+// History returns the events matching the filter, most recent first
+func History(zr repository.ZettelRepository, filter *model.EventFilter) ([]*model.Event, error) {
+	return zr.Events(context.Background(), filter)
 }
 
-func Backlog(zr repository.ZettelRepository) ([]*model.Zettel, error) {
-	return zr.ListFleet(context.Background())
+// Recent returns the zettels of the history with their last event matching
+// the filter, most recent first
+func Recent(zr repository.ZettelRepository, filter *model.EventFilter) ([]*model.Zettel, error) {
+	return zr.Recent(context.Background(), filter)
+}
+
+// NewEventFilter parses the flags of `zet history`, since is a duration like
+// 7d and is ignored when empty
+func NewEventFilter(since string, kind string, limit int) (*model.EventFilter, error) {
+	filter := &model.EventFilter{Kind: kind, Limit: limit}
+
+	if since != "" {
+		d, err := parseDuration(since)
+		if err != nil {
+			return nil, err
+		}
+		filter.Since = time.Now().Add(-d)
+	}
+
+	return filter, nil
 }
 
 func Links(zr repository.ZettelRepository, path string) ([]*model.Zettel, error) {
@@ -85,7 +176,9 @@ func BackLinks(zr repository.ZettelRepository, path string) ([]*model.Zettel, er
 	return zettels, nil
 }
 
-func Permanent(zr repository.ZettelRepository, path string) (*model.Zettel, error) {
+// Permanent promotes a zettel once it follows the promotion rules of the
+// config, failing with a LintError otherwise. Force skips the rules.
+func Permanent(zr repository.ZettelRepository, path string, force bool) (*model.Zettel, error) {
 	// transform the zettel to permanent
 	zet := &model.Zettel{
 		Path: path,
@@ -95,15 +188,34 @@ func Permanent(zr repository.ZettelRepository, path string) (*model.Zettel, erro
 		return nil, err
 	}
 
+	if !force {
+		violations, err := lintZettel(zr, zet)
+		if err != nil {
+			return nil, err
+		}
+		if len(violations) > 0 {
+			return nil, &LintError{Violations: violations}
+		}
+	}
+
 	zet.Type = "permanent"
 	zet.Path = zr.Config().PermanentRoot + "/" + zet.Slug + ".md"
 
+	// Move the file to the permanent directory, it fails if there's already a
+	// zettel there
+	if err := fs.Move(path, zet.Path); err != nil {
+		return nil, err
+	}
+
 	if err := zr.Save(context.Background(), zet); err != nil {
+		// put the file back, so the database and the filesystem agree
+		if err := fs.Move(zet.Path, path); err != nil {
+			log.Printf("warning: failed to move %s back to %s: %v\n", zet.Path, path, err)
+		}
 		return nil, err
 	}
 
-	// Move the file to the permanent directory
-	if err := fs.Move(path, zet.Path); err != nil {
+	if err := zr.InsertEvent(context.Background(), zet, model.EventPromoted); err != nil {
 		return nil, err
 	}
 
@@ -122,15 +234,59 @@ func Fleet(zr repository.ZettelRepository, path string) (*model.Zettel, error) {
 	zet.Type = "fleet"
 	zet.Path = zr.Config().FleetRoot + "/" + zet.Slug + ".md"
 
+	// Move the file to the fleet directory, it fails if there's already a
+	// zettel there
+	if err := fs.Move(path, zet.Path); err != nil {
+		return nil, err
+	}
+
 	if err := zr.Save(context.Background(), zet); err != nil {
+		// put the file back, so the database and the filesystem agree
+		if err := fs.Move(zet.Path, path); err != nil {
+			log.Printf("warning: failed to move %s back to %s: %v\n", zet.Path, path, err)
+		}
 		return nil, err
 	}
 
-	// Move the file to the fleet directory
+	return zet, nil
+}
+
+// Archive puts a fleet zettel aside, out of the backlog, without removing it
+func Archive(zr repository.ZettelRepository, path string) (*model.Zettel, error) {
+	zet := &model.Zettel{
+		Path: path,
+	}
+
+	if err := zr.Get(context.Background(), zet); err != nil {
+		return nil, err
+	}
+
+	// only the fleet is triaged, the permanent zettels stay where they are
+	if zet.Type != "fleet" {
+		return nil, fmt.Errorf("%w: %s is %s, only fleet zettels can be archived", repository.ErrInvalidZettel, path, zet.Type)
+	}
+
+	zet.Type = "archive"
+	zet.Path = zr.Config().ArchiveRoot + "/" + zet.Slug + ".md"
+
+	// Move the file to the archive directory, it fails if there's already a
+	// zettel there
 	if err := fs.Move(path, zet.Path); err != nil {
 		return nil, err
 	}
 
+	if err := zr.Save(context.Background(), zet); err != nil {
+		// put the file back, so the database and the filesystem agree
+		if err := fs.Move(zet.Path, path); err != nil {
+			log.Printf("warning: failed to move %s back to %s: %v\n", zet.Path, path, err)
+		}
+		return nil, err
+	}
+
+	if err := zr.InsertEvent(context.Background(), zet, model.EventArchived); err != nil {
+		return nil, err
+	}
+
 	return zet, nil
 }
 
@@ -151,8 +307,8 @@ func BrokenLinks(zr repository.ZettelRepository) ([]*model.Zettel, error) {
 		}
 
 		for _, link := range zet.Links {
-			if err := zr.Get(context.Background(), link); err != nil {
-				if err == repository.ErrZettelNotFound || err == repository.ErrNoZettel {
+			if err := zr.Resolve(context.Background(), link); err != nil {
+				if err == repository.ErrZettelNotFound || err == repository.ErrNoZettel || errors.Is(err, repository.ErrZettelAmbiguous) {
 					brokenZettels = append(brokenZettels, zet)
 				} else {
 					return nil, err
@@ -174,8 +330,23 @@ func Last(zr repository.ZettelRepository) (*model.Zettel, error) {
 	return zet, nil
 }
 
-func InsertHistory(zr repository.ZettelRepository, zet *model.Zettel) error {
-	return zr.InsertHistory(context.Background(), zet)
+func InsertEvent(zr repository.ZettelRepository, zet *model.Zettel, kind string) error {
+	return zr.InsertEvent(context.Background(), zet, kind)
+}
+
+// Open records that the zettel was opened
+func Open(zr repository.ZettelRepository, path string) (*model.Zettel, error) {
+	zet := &model.Zettel{Path: path}
+
+	if err := zr.Get(context.Background(), zet); err != nil {
+		return nil, err
+	}
+
+	if err := zr.InsertEvent(context.Background(), zet, model.EventOpened); err != nil {
+		return nil, err
+	}
+
+	return zet, nil
 }
 
 func Save(zr repository.ZettelRepository, path string) (*model.Zettel, error) {
@@ -191,13 +362,13 @@ func Save(zr repository.ZettelRepository, path string) (*model.Zettel, error) {
 		return nil, err
 	}
 
-	if err := zr.InsertHistory(context.Background(), zet); err != nil {
+	if err := zr.InsertEvent(context.Background(), zet, model.EventSaved); err != nil {
 		return nil, err
 	}
 
 	// We need to expand the links by the slug to get the full zettel
 	for _, link := range zet.Links {
-		if err := zr.Get(context.Background(), link); err != nil {
+		if err := zr.Resolve(context.Background(), link); err != nil {
 			return nil, err
 		}
 	}
@@ -212,13 +383,23 @@ func Save(zr repository.ZettelRepository, path string) (*model.Zettel, error) {
 	return zet, nil
 }
 
-func Sync(zr repository.ZettelRepository) error {
+// SyncReport is what zet sync did to the index
+type SyncReport struct {
+	// zettels read from the fleet, permanent and archive roots
+	Indexed int `json:"indexed"`
+	Links   int `json:"links"`
+	// zettels whose files are gone
+	Removed []*model.Zettel `json:"removed"`
+}
+
+func Sync(zr repository.ZettelRepository) (*SyncReport, error) {
 	cfg := zr.Config()
 
 	fleet := fs.List(cfg.FleetRoot)
 	perm := fs.List(cfg.PermanentRoot)
 
 	paths := append(fleet, perm...)
+	paths = append(paths, fs.List(cfg.ArchiveRoot)...)
 
 	var zettels []*model.Zettel
 	for _, path := range paths {
@@ -226,24 +407,28 @@ func Sync(zr repository.ZettelRepository) error {
 			Path: path,
 		}
 		if err := zet.Read(cfg); err != nil {
-			return err
+			return nil, err
 		}
 		zettels = append(zettels, zet)
 	}
 
 	if err := zr.SaveBulk(context.Background(), zettels...); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Retrieve all links from slug
 	for _, zet := range zettels {
 		for _, link := range zet.Links {
-			if err := zr.Get(context.Background(), link); err != nil {
+			if err := zr.Resolve(context.Background(), link); err != nil {
 				if err == repository.ErrZettelNotFound || err == repository.ErrNoZettel {
 					log.Printf("warning: link not found: [[%s]] in %s\n", link.Slug, zet.Path)
 					continue
 				}
-				return err
+				if errors.Is(err, repository.ErrZettelAmbiguous) {
+					log.Printf("warning: %v in %s\n", err, zet.Path)
+					continue
+				}
+				return nil, err
 			}
 		}
 	}
@@ -263,7 +448,7 @@ func Sync(zr repository.ZettelRepository) error {
 
 	if len(links) > 0 {
 		if err := zr.LinkBulk(context.Background(), links...); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -272,8 +457,14 @@ func Sync(zr repository.ZettelRepository) error {
 
 	dbZettel, err := zr.ListAll(context.Background())
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	archived, err := zr.ListArchive(context.Background())
+	if err != nil {
+		return nil, err
 	}
+	dbZettel = append(dbZettel, archived...)
 
 	var toRemove []*model.Zettel
 	for _, zet := range dbZettel {
@@ -282,11 +473,36 @@ func Sync(zr repository.ZettelRepository) error {
 		}
 	}
 
+	report := &SyncReport{Indexed: len(zettels), Links: len(links), Removed: toRemove}
+	if report.Removed == nil {
+		report.Removed = []*model.Zettel{}
+	}
+
 	if len(toRemove) > 0 {
 		if err := zr.RemoveBulk(context.Background(), toRemove...); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	return report, nil
+}
+
+type Graph struct {
+	Nodes []*model.Zettel `json:"nodes"`
+	Edges []*model.Link   `json:"edges"`
+}
+
+// ZettelGraph returns every zettel and every link between them
+func ZettelGraph(zr repository.ZettelRepository) (*Graph, error) {
+	nodes, err := zr.ListAll(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	edges, err := zr.ListLinks(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Graph{Nodes: nodes, Edges: edges}, nil
 }
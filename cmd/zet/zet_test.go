@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/muxit-studio/test/assert"
 	"github.com/muxit-studio/test/require"
@@ -56,8 +61,10 @@ func TestNewZet(t *testing.T) {
 		err := zr.Reset(context.Background())
 		require.Equal(t, err, nil, "failed to reset database")
 
-		err = Sync(zr)
+		report, err := Sync(zr)
 		require.Equal(t, err, nil, "failed to sync")
+		assert.Equal(t, report.Indexed, 3, "every zettel should be indexed")
+		assert.Equal(t, report.Links, 2, "the links of z3 should be indexed")
 
 		err = zr.Get(context.Background(), z3)
 		require.Equal(t, err, nil, "failed to fetch z3")
@@ -79,31 +86,270 @@ func TestNewZet(t *testing.T) {
 
 		z1 := createZet(t, zr, "A title one")
 		z2 := createZet(t, zr, "A title two")
-		z3 :=	createZet(t, zr, "A title three")
+		z3 := createZet(t, zr, "A title three")
 
-		assert.Equal(t, InsertHistory(zr, z1), nil, "failed to insert history")
-		assert.Equal(t, InsertHistory(zr, z2), nil, "failed to insert history")
-		assert.Equal(t, InsertHistory(zr, z3), nil, "failed to insert history")
+		assert.Equal(t, InsertEvent(zr, z1, model.EventOpened), nil, "failed to insert history")
+		assert.Equal(t, InsertEvent(zr, z2, model.EventOpened), nil, "failed to insert history")
+		assert.Equal(t, InsertEvent(zr, z3, model.EventOpened), nil, "failed to insert history")
 
-		history, err := History(zr)
+		history, err := Recent(zr, nil)
 		require.Equal(t, err, nil, "failed to get history")
 
 		assert.Equal(t, len(history), 3, "history != 3")
-		assert.Equal(t, history[2].Title, "A title three", "history[0].Title != 'A title three'")
+		assert.Equal(t, history[0].Title, "A title three", "history[0].Title != 'A title three'")
 		assert.Equal(t, history[1].Title, "A title two", "history[1].Title != 'A title two'")
-		assert.Equal(t, history[0].Title, "A title one", "history[2].Title != 'A title one'")
+		assert.Equal(t, history[2].Title, "A title one", "history[2].Title != 'A title one'")
+	})
+
+	t.Run("create -> open -> save -> promote -> remove -> history", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z2 := createZet(t, zr, "A title two")
+
+		_, err := Open(zr, z1.Path)
+		require.Equal(t, err, nil, "failed to open z1")
+		_, err = Open(zr, z1.Path)
+		require.Equal(t, err, nil, "failed to open z1 again")
+
+		z2.WriteLine("An edited line")
+		saveZet(t, zr, z2)
+
+		z1, err = Permanent(zr, z1.Path, false)
+		require.Equal(t, err, nil, "failed to make z1 permanent")
+
+		_, err = Remove(zr, z2.Path)
+		require.Equal(t, err, nil, "failed to remove z2")
+
+		events, err := History(zr, nil)
+		require.Equal(t, err, nil, "failed to get the events")
+		require.Equal(t, len(events), 7, "every event should be kept")
+		assert.Equal(t, events[0].Kind, model.EventRemoved, "last event should be the removal")
+		assert.Equal(t, events[0].Title, "A title two", "removed zettels should keep their title")
+		assert.Equal(t, events[1].Kind, model.EventPromoted, "z1 should be promoted")
+
+		events, err = History(zr, &model.EventFilter{Kind: model.EventOpened, Limit: 1})
+		require.Equal(t, err, nil, "failed to filter the events")
+		require.Equal(t, len(events), 1, "events should be limited")
+		assert.Equal(t, events[0].ZettelID, z1.ID, "z1 should be opened")
+
+		filter, err := NewEventFilter("1h", "", 0)
+		require.Equal(t, err, nil, "failed to parse the filter")
+		filter.Since = filter.Since.Add(2 * time.Hour)
+		events, err = History(zr, filter)
+		require.Equal(t, err, nil, "failed to filter the events")
+		assert.Equal(t, len(events), 0, "no event should be in the future")
+
+		_, err = History(zr, &model.EventFilter{Kind: "edited"})
+		assert.Equal(t, errors.Is(err, repository.ErrInvalidKind), true, "unknown kinds should be refused")
+
+		recent, err := Recent(zr, nil)
+		require.Equal(t, err, nil, "failed to get the recent zettels")
+		require.Equal(t, len(recent), 1, "removed zettels should not be recent")
+		assert.Equal(t, recent[0].ID, z1.ID, "z1 should be recent once")
+
+		last, err := Last(zr)
+		require.Equal(t, err, nil, "failed to get the last zettel")
+		assert.Equal(t, last.ID, z1.ID, "z1 should be the last zettel")
+	})
+}
+
+func TestTrash(t *testing.T) {
+	t.Run("remove -> restore -> links are back", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z2 := createZet(t, zr, "A title two")
+		z2.WriteLine(fmt.Sprintf("This zet is linked to [[%s]]", z1.Slug))
+		z2 = saveZet(t, zr, z2)
+
+		err := zr.Get(context.Background(), z1)
+		require.Equal(t, err, nil, "failed to get z1")
+		updatedAt := z1.UpdatedAt.T
+
+		_, err = Remove(zr, z1.Path)
+		require.Equal(t, err, nil, "failed to remove z1")
+		assert.Equal(t, fs.Exists(z1.Path), false, "z1 file should be in the trash")
+
+		trash, err := TrashList(zr)
+		require.Equal(t, err, nil, "failed to list trash")
+		require.Equal(t, len(trash), 1, "trash should have z1")
+		assert.Equal(t, trash[0].ID, z1.ID, "trash should have z1")
+		assert.Equal(t, fs.Exists(trash[0].TrashPath), true, "z1 should be in the trash folder")
+
+		restored, err := Restore(zr, z1.ID)
+		require.Equal(t, err, nil, "failed to restore z1")
+		assert.Equal(t, restored.Path, z1.Path, "z1 should be restored to its path")
+		assert.Equal(t, fs.Exists(z1.Path), true, "z1 file should be restored")
+
+		err = zr.Get(context.Background(), z1)
+		require.Equal(t, err, nil, "failed to get the restored z1")
+		assert.Equal(t, z1.UpdatedAt.T.Equal(updatedAt), true, "z1 should keep its last update")
+
+		backlinks, err := BackLinks(zr, z1.Path)
+		require.Equal(t, err, nil, "failed to get backlinks")
+		require.Equal(t, len(backlinks), 1, "z1 should have its backlink restored")
+		assert.Equal(t, backlinks[0].ID, z2.ID, "z2 should link to z1")
+	})
+
+	t.Run("failed removes leave the zettel in place", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, cfg := startup(t)
+
+		// a file left in the trash
+		z1 := createZet(t, zr, "A title one")
+		err := fs.Write(cfg.TrashRoot+"/"+z1.ID+".md", "# A stale title\n")
+		require.Equal(t, err, nil, "failed to write the stale file")
+
+		_, err = Remove(zr, z1.Path)
+		assert.Equal(t, errors.Is(err, os.ErrExist), true, "the stale file should not be overwritten")
+		assert.Equal(t, fs.Exists(z1.Path), true, "z1 file should stay")
+		err = zr.Get(context.Background(), &model.Zettel{ID: z1.ID})
+		assert.Equal(t, err, nil, "z1 should stay in the database")
+
+		require.Equal(t, fs.Remove(cfg.TrashRoot+"/"+z1.ID+".md"), nil, "failed to remove the stale file")
+		_, err = Remove(zr, z1.Path)
+		require.Equal(t, err, nil, "failed to remove z1")
+		require.Equal(t, fs.Remove(cfg.TrashRoot+"/"+z1.ID+".md"), nil, "failed to remove the trashed file")
+
+		// z1 is back under the same id, the trash already has it so the move is
+		// undone
+		err = zr.Save(context.Background(), &model.Zettel{ID: z1.ID, Title: z1.Title, Path: z1.Path})
+		require.Equal(t, err, nil, "failed to save z1 again")
+		require.Equal(t, fs.Write(z1.Path, "# A title one\n"), nil, "failed to write z1 again")
+
+		_, err = Remove(zr, z1.Path)
+		assert.NotEqual(t, err, nil, "z1 should already be in the trash")
+		assert.Equal(t, fs.Exists(z1.Path), true, "z1 file should be moved back")
+		assert.Equal(t, fs.Exists(cfg.TrashRoot+"/"+z1.ID+".md"), false, "z1 file should not stay in the trash")
+	})
+
+	t.Run("empty the trash", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, _ := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+
+		_, err := Remove(zr, z1.Path)
+		require.Equal(t, err, nil, "failed to remove z1")
+
+		removed, err := EmptyTrash(zr, time.Hour)
+		require.Equal(t, err, nil, "failed to empty trash")
+		assert.Equal(t, len(removed), 0, "z1 is not older than an hour")
+
+		removed, err = EmptyTrash(zr, 0)
+		require.Equal(t, err, nil, "failed to empty trash")
+		require.Equal(t, len(removed), 1, "z1 should be removed")
+		assert.Equal(t, fs.Exists(removed[0].TrashPath), false, "z1 file should be removed")
+
+		_, err = Restore(zr, z1.ID)
+		assert.Equal(t, err, repository.ErrZettelNotFound, "z1 should be gone")
+	})
+}
+
+func TestArchive(t *testing.T) {
+	t.Run("fleet -> archive, permanent zettels are refused", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, cfg := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z2 := createZet(t, zr, "A title two")
+
+		archived, err := Archive(zr, z1.Path)
+		require.Equal(t, err, nil, "failed to archive z1")
+		assert.Equal(t, archived.Path, cfg.ArchiveRoot+"/"+z1.Slug+".md", "z1 should be in the archive")
+
+		events, err := zr.Events(context.Background(), &model.EventFilter{Kind: model.EventArchived})
+		require.Equal(t, err, nil, "failed to query the events")
+		require.Equal(t, len(events), 1, "the archive should be recorded")
+		assert.Equal(t, events[0].ZettelID, z1.ID, "the archive of z1 should be recorded")
+
+		_, err = Archive(zr, archived.Path)
+		assert.Equal(t, errors.Is(err, repository.ErrInvalidZettel), true, "archived zettels should be refused")
+
+		z2, err = Permanent(zr, z2.Path, false)
+		require.Equal(t, err, nil, "failed to make z2 permanent")
+
+		_, err = Archive(zr, z2.Path)
+		assert.Equal(t, errors.Is(err, repository.ErrInvalidZettel), true, "permanent zettels should be refused")
+		assert.Equal(t, fs.Exists(z2.Path), true, "z2 should stay in the permanent root")
+	})
+}
+
+func TestDoctor(t *testing.T) {
+	t.Run("reports and fixes missing files", func(t *testing.T) {
+		t.Cleanup(func() {
+			cleanup(t)
+		})
+
+		zr, cfg := startup(t)
+
+		z1 := createZet(t, zr, "A title one")
+		z2 := createZet(t, zr, "A title two")
+
+		err := fs.Remove(z1.Path)
+		require.Equal(t, err, nil, "failed to remove z1 file")
+
+		err = fs.Write(cfg.FleetRoot+"/Not An Id.md", "no title here\n")
+		require.Equal(t, err, nil, "failed to write invalid file")
+
+		// a slug, but not an id
+		err = fs.Write(cfg.FleetRoot+"/a-title-three.md", "# A title three\n")
+		require.Equal(t, err, nil, "failed to write non-id file")
+
+		issues, err := Doctor(zr, true)
+		require.Equal(t, err, nil, "failed to run doctor")
+
+		kinds := make(map[string]*Issue)
+		var invalid []string
+		for _, issue := range issues {
+			kinds[issue.Kind] = issue
+			if issue.Kind == IssueInvalidFilename {
+				invalid = append(invalid, filepath.Base(issue.Path))
+			}
+		}
+
+		require.NotEqual(t, kinds[IssueMissingFile], nil, "missing file should be reported")
+		assert.Equal(t, kinds[IssueMissingFile].ID, z1.ID, "z1 should be reported as missing")
+		assert.Equal(t, kinds[IssueMissingFile].Fixed, true, "missing file should be fixed")
+		sort.Strings(invalid)
+		assert.Equal(t, strings.Join(invalid, ","), "Not An Id.md,a-title-three.md", "filenames that are not ids should be reported")
+
+		err = zr.Get(context.Background(), z1)
+		assert.Equal(t, err, repository.ErrZettelNotFound, "z1 should be removed from the database")
+
+		err = zr.Get(context.Background(), z2)
+		assert.Equal(t, err, nil, "z2 should still exist")
 	})
 }
 
 func startup(t *testing.T) (repository.ZettelRepository, *config.Config) {
 	cfg := config.New("/tmp/zet-cmd")
 	db := sqltest.CreateDatabase(t, cfg)
-	zr := repository.NewZettelRepository(db, cfg)
+	zr, err := repository.NewZettelRepository(db, cfg)
+	require.Equal(t, err, nil, "failed to create the repository")
 	return zr, cfg
 }
 
 func createZet(t *testing.T, zr repository.ZettelRepository, title string) *model.Zettel {
-	zet, err := New(zr, title)
+	zet, err := New(zr, title, "")
 	require.Equal(t, err, nil, "failed to create zettel")
 	return zet
 }
@@ -125,4 +371,16 @@ func cleanup(t *testing.T) {
 
 	err = fs.RemoveAll(cfg.PermanentRoot)
 	require.Equal(t, err, nil, "failed to remove fleet root")
+
+	_, err = zr.EmptyTrash(context.Background(), time.Now().Add(time.Hour))
+	require.Equal(t, err, nil, "failed to empty the trash")
+
+	err = fs.RemoveAll(cfg.TrashRoot)
+	require.Equal(t, err, nil, "failed to remove trash root")
+
+	err = fs.RemoveAll(cfg.AttachmentsRoot)
+	require.Equal(t, err, nil, "failed to remove attachments root")
+
+	err = fs.RemoveAll(cfg.ArchiveRoot)
+	require.Equal(t, err, nil, "failed to remove archive root")
 }
@@ -3,12 +3,15 @@ module github.com/odas0r/zet
 go 1.18
 
 require (
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/gosimple/slug v1.13.1
 	github.com/jmoiron/sqlx v1.3.5
 	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/muxit-studio/test v0.1.1
+	github.com/oklog/ulid/v2 v2.1.0
 	github.com/pressly/goose v2.7.0+incompatible
 	github.com/urfave/cli/v2 v2.4.0
+	github.com/yuin/goldmark v1.6.0
 )
 
 require (
@@ -18,4 +21,5 @@ require (
 	github.com/muxit-studio/columnize v0.0.0-20200819155840-d363dedc9af5 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
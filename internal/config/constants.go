@@ -4,17 +4,55 @@ import (
 	"github.com/odas0r/zet/pkg/fs"
 )
 
+// Formats of the zettel ids
+const (
+	// 20220605165935123, a timestamp with millisecond precision
+	IDFormatTimestamp = "timestamp"
+	// 01h5s0v0m8k9k7y2d3h4j5k6m7, a lowercase ulid
+	IDFormatULID = "ulid"
+	// 1, 1a, 1a1, 1a2, 1b, 2, ... folgezettel style ids
+	IDFormatFolgezettel = "folgezettel"
+)
+
+// PromotionRules are checked before a fleet zettel becomes permanent, see
+// `zet lint`. The zero value checks nothing.
+type PromotionRules struct {
+	// words of the body, without the title
+	MinWords int `json:"minWords"`
+	// at least one [[link]] to another zettel
+	RequireLink bool `json:"requireLink"`
+	// at least one #tag
+	RequireTag bool `json:"requireTag"`
+	// every [[link]] resolves to a zettel
+	NoBrokenLinks bool `json:"noBrokenLinks"`
+	// titles that are not a title yet, compared ignoring the case
+	Placeholders []string `json:"placeholders"`
+}
+
 type Config struct {
 	Root          string
 	FleetRoot     string
 	PermanentRoot string
+	TrashRoot     string
+	// fleet zettels put aside during the triage, out of the backlog
+	ArchiveRoot string
+	// files linked from the zettels, like images, created on demand
+	AttachmentsRoot string
+	IDFormat        string
+	// optional, recorded with the events of the history
+	Session   string
+	Promotion PromotionRules
 }
 
 func New(root string) *Config {
 	cfg := &Config{
-		Root:          root,
-		FleetRoot:     root + "/fleet",
-		PermanentRoot: root + "/permanent",
+		Root:            root,
+		FleetRoot:       root + "/fleet",
+		PermanentRoot:   root + "/permanent",
+		TrashRoot:       root + "/.trash",
+		ArchiveRoot:     root + "/archive",
+		AttachmentsRoot: root + "/attachments",
+		IDFormat:        IDFormatTimestamp,
 	}
 
 	if err := cfg.createRoot(); err != nil {
@@ -38,5 +76,13 @@ func (c *Config) createRoot() error {
 		return err
 	}
 
+	if err := fs.Mkdir(c.TrashRoot); err != nil {
+		return err
+	}
+
+	if err := fs.Mkdir(c.ArchiveRoot); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// File is the name of the optional user config in the root, a JSON object
+// like {"idFormat": "ulid", "promotion": {"minWords": 50, "requireTag": true}}
+const File = "zet.json"
+
+// IDFormatEnv overrides the id format of the user config
+const IDFormatEnv = "ZET_ID_FORMAT"
+
+type file struct {
+	IDFormat  string          `json:"idFormat"`
+	Promotion *PromotionRules `json:"promotion"`
+}
+
+// Load reads the user config from the root, when there is one, over the
+// defaults of New, then the environment over both
+func (c *Config) Load() error {
+	if err := c.loadFile(); err != nil {
+		return err
+	}
+
+	if format := os.Getenv(IDFormatEnv); format != "" {
+		c.IDFormat = format
+	}
+
+	return nil
+}
+
+func (c *Config) loadFile() error {
+	path := filepath.Join(c.Root, File)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error: failed to read %s: %w", path, err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("error: invalid config %s: %w", path, err)
+	}
+
+	if f.IDFormat != "" {
+		c.IDFormat = f.IDFormat
+	}
+	if f.Promotion != nil {
+		c.Promotion = *f.Promotion
+	}
+
+	return nil
+}
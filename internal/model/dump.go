@@ -0,0 +1,44 @@
+package model
+
+// Dump is a zettel with everything needed to rebuild its file and its rows,
+// one per line of `zet dump`
+type Dump struct {
+	ID    string `json:"id"`
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+	// relative to the root, so a dump can be restored on another machine
+	Path      string `json:"path"`
+	Type      string `json:"type"`
+	CreatedAt Time   `json:"createdAt"`
+	UpdatedAt Time   `json:"updatedAt"`
+	Content   string `json:"content"`
+
+	// ids of the linked zettels
+	Links  []string     `json:"links"`
+	Events []*DumpEvent `json:"events,omitempty"`
+	// slugs of the zettels merged into it
+	Aliases []string `json:"aliases,omitempty"`
+	// schedule of a permanent zettel in the review queue
+	Review *DumpReview `json:"review,omitempty"`
+
+	// derived from the content, only informative
+	Tags []string `json:"tags"`
+}
+
+// DumpEvent is an event of the history of a zettel, oldest first
+type DumpEvent struct {
+	Kind      string `json:"kind"`
+	Session   string `json:"session,omitempty"`
+	CreatedAt Time   `json:"createdAt"`
+}
+
+// DumpReview is the spaced repetition schedule of a zettel, see model.Review
+type DumpReview struct {
+	Repetitions int     `json:"repetitions"`
+	Interval    int     `json:"interval"`
+	Ease        float64 `json:"ease"`
+	DueAt       Time    `json:"dueAt"`
+	ReviewedAt  *Time   `json:"reviewedAt,omitempty"`
+	CreatedAt   Time    `json:"createdAt"`
+	UpdatedAt   Time    `json:"updatedAt"`
+}
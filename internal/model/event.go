@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// Kinds of the events of the history
+const (
+	EventOpened   = "opened"
+	EventSaved    = "saved"
+	EventCreated  = "created"
+	EventPromoted = "promoted"
+	EventArchived = "archived"
+	EventRemoved  = "removed"
+)
+
+var EventKinds = []string{EventOpened, EventSaved, EventCreated, EventPromoted, EventArchived, EventRemoved}
+
+// Event is something that happened to a zettel, the history is the log of
+// every event
+type Event struct {
+	ID       int64  `db:"id" json:"id"`
+	ZettelID string `db:"zettel_id" json:"zettelId"`
+	Kind     string `db:"kind" json:"kind"`
+	// optional, groups the events of an editor session
+	Session   string `db:"session" json:"session,omitempty"`
+	CreatedAt Time   `db:"created_at" json:"createdAt"`
+
+	// title of the zettel, found in the trash for the removed ones
+	Title string `db:"title" json:"title"`
+}
+
+// EventFilter narrows the history, the zero value keeps every event
+type EventFilter struct {
+	// events after this time
+	Since time.Time
+	// every kind when empty
+	Kind string
+	// no limit when zero
+	Limit int
+}
@@ -0,0 +1,141 @@
+package model
+
+import (
+	"strings"
+)
+
+// FrontMatter holds the fields of a YAML front matter block. Only the subset
+// used by markdown vaults is supported: scalars, inline lists [a, b] and block
+// lists of "- item" lines. Every value is kept as a list of strings.
+type FrontMatter map[string][]string
+
+// Get returns the first value of the field, or an empty string
+func (fm FrontMatter) Get(key string) string {
+	if values := fm[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// ParseFrontMatter splits the "---" delimited front matter from the content,
+// returning its fields and the rest of the content. Content without front
+// matter is returned as is.
+func ParseFrontMatter(content string) (FrontMatter, string) {
+	fm := FrontMatter{}
+
+	lines := strings.Split(content, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return fm, content
+	}
+
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			end = i
+			break
+		}
+	}
+	if end == -1 {
+		return fm, content
+	}
+
+	key := ""
+	for _, line := range lines[1:end] {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// item of a block list, belongs to the last key
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if key != "" {
+				if value := unquote(strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))); value != "" {
+					fm[key] = append(fm[key], value)
+				}
+			}
+			continue
+		}
+
+		k, v, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+
+		key = strings.TrimSpace(k)
+		v = strings.TrimSpace(v)
+
+		switch {
+		case v == "":
+			fm[key] = []string{}
+		case strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]"):
+			values := []string{}
+			for _, item := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(v, "["), "]"), ",") {
+				if item = unquote(strings.TrimSpace(item)); item != "" {
+					values = append(values, item)
+				}
+			}
+			fm[key] = values
+		default:
+			fm[key] = []string{unquote(v)}
+		}
+	}
+
+	return fm, strings.TrimLeft(strings.Join(lines[end+1:], "\n"), "\n")
+}
+
+// String formats the front matter with the keys in the given order, the
+// values of the list keys are always written as inline lists. Missing keys
+// are skipped.
+func (fm FrontMatter) String(keys []string, lists ...string) string {
+	var b strings.Builder
+
+	b.WriteString("---\n")
+	for _, key := range keys {
+		values, ok := fm[key]
+		if !ok {
+			continue
+		}
+
+		isList := len(values) != 1
+		for _, list := range lists {
+			isList = isList || list == key
+		}
+
+		if !isList {
+			b.WriteString(key + ": " + quote(values[0]) + "\n")
+			continue
+		}
+
+		quoted := make([]string, len(values))
+		for i, value := range values {
+			quoted[i] = quote(value)
+		}
+		b.WriteString(key + ": [" + strings.Join(quoted, ", ") + "]\n")
+	}
+	b.WriteString("---\n")
+
+	return b.String()
+}
+
+func unquote(s string) string {
+	if len(s) < 2 || s[len(s)-1] != s[0] {
+		return s
+	}
+
+	switch s[0] {
+	case '"':
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s[1 : len(s)-1])
+	case '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+
+	return s
+}
+
+// quote quotes the values that would not be read back as plain strings
+func quote(s string) string {
+	if s != "" && strings.TrimSpace(s) == s && !strings.ContainsAny(s, ":#[]{},&*!|>'\"%@`\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
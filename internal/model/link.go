@@ -1,8 +1,8 @@
 package model
 
 type Link struct {
-	From      string `db:"zettel_id"`
-	To        string `db:"link_id"`
-	CreatedAt Time   `db:"created_at"`
-	UpdatedAt Time   `db:"updated_at"`
+	From      string `db:"zettel_id" json:"from"`
+	To        string `db:"link_id" json:"to"`
+	CreatedAt Time   `db:"created_at" json:"createdAt"`
+	UpdatedAt Time   `db:"updated_at" json:"updatedAt"`
 }
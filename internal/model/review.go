@@ -0,0 +1,66 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+// Bounds of the rating of a review, from 1 (forgotten) to 5 (perfect recall)
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+const minEase = 1.3
+
+// Review is the spaced repetition schedule of a permanent zettel
+type Review struct {
+	ZettelID    string  `db:"zettel_id" json:"zettelId"`
+	Repetitions int     `db:"repetitions" json:"repetitions"`
+	Interval    int     `db:"interval" json:"interval"`
+	Ease        float64 `db:"ease" json:"ease"`
+	DueAt       Time    `db:"due_at" json:"dueAt"`
+	ReviewedAt  *Time   `db:"reviewed_at" json:"reviewedAt"`
+	CreatedAt   Time    `db:"created_at" json:"createdAt"`
+	UpdatedAt   Time    `db:"updated_at" json:"updatedAt"`
+}
+
+// ReviewStats counts the reviews for the editor statusline
+type ReviewStats struct {
+	// due now
+	Due int `db:"due" json:"due"`
+	// due by the end of the day
+	DueToday int `db:"due_today" json:"dueToday"`
+	Total    int `db:"total" json:"total"`
+}
+
+// Rate schedules the next review with the SM-2 algorithm. The rating goes
+// from 1 to 5, below 3 the zettel was not recalled and its repetitions start
+// over.
+func (r *Review) Rate(rating int, now time.Time) {
+	// SM-2 grades go from 0 to 5
+	q := float64(rating)
+
+	if rating < 3 {
+		r.Repetitions = 0
+		r.Interval = 1
+	} else {
+		switch r.Repetitions {
+		case 0:
+			r.Interval = 1
+		case 1:
+			r.Interval = 6
+		default:
+			r.Interval = int(math.Round(float64(r.Interval) * r.Ease))
+		}
+		r.Repetitions++
+	}
+
+	r.Ease += 0.1 - (5-q)*(0.08+(5-q)*0.02)
+	if r.Ease < minEase {
+		r.Ease = minEase
+	}
+
+	r.ReviewedAt = &Time{T: now.UTC()}
+	r.DueAt = Time{T: now.UTC().AddDate(0, 0, r.Interval)}
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// a #tag starts with a letter and is preceded by a space or the start of the
+// line, so anchors like [link](#heading) are not tags
+var tagRe = regexp.MustCompile(`(?:^|\s)#([a-zA-Z][\w/-]*)`)
+
+// Tags returns the lowercase #tags of the content, sorted and without
+// duplicates. Headings and fenced code blocks are skipped.
+func (z *Zettel) Tags() []string {
+	seen := make(map[string]bool)
+	tags := []string{}
+
+	fenced := false
+	for _, line := range strings.Split(z.Content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced || isHeading(trimmed) {
+			continue
+		}
+
+		for _, match := range tagRe.FindAllStringSubmatch(line, -1) {
+			tag := strings.ToLower(match[1])
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	sort.Strings(tags)
+
+	return tags
+}
+
+// isHeading reports whether the line is a markdown heading, like "## Title"
+func isHeading(line string) bool {
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	return level > 0 && level <= 6 && (len(line) == level || line[level] == ' ')
+}
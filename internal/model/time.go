@@ -2,6 +2,7 @@ package model
 
 import (
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -44,3 +45,13 @@ func (t *Time) Scan(src any) error {
 
 	return nil
 }
+
+// UnmarshalJSON satisfies json.Unmarshaler interface.
+func (t *Time) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	return t.Scan(s)
+}
@@ -0,0 +1,9 @@
+package model
+
+// Trash is a removed zettel, kept until the trash is emptied
+type Trash struct {
+	Zettel
+
+	TrashPath string `db:"trash_path" json:"trashPath"`
+	RemovedAt Time   `db:"removed_at" json:"removedAt"`
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -10,7 +11,13 @@ import (
 	"github.com/odas0r/zet/pkg/fs"
 )
 
+// ErrInvalidZettel is returned for files that are not zettels of the
+// configured roots and for zettels that cannot be saved
+var ErrInvalidZettel = errors.New("error: zettel is not valid")
+
 type Zettel struct {
+	// Rowid keys the search index, it is internal to the database
+	Rowid     int64  `db:"rowid" json:"-"`
 	ID        string `db:"id" json:"id"`
 	Slug      string `db:"slug" json:"slug"`
 	Title     string `db:"title" json:"title"`
@@ -27,12 +34,32 @@ type Zettel struct {
 	Links []*Zettel `json:"-"`
 }
 
+// NewLink returns the zettel the text of a [[link]] points to. Links written
+// as a title keep it, so they can be resolved by title, see
+// ZettelRepository.Resolve. A #heading suffix, as in ![[slug#heading]] embeds,
+// is not part of the link.
+func NewLink(text string) *Zettel {
+	if i := strings.Index(text, "#"); i > 0 {
+		text = text[:i]
+	}
+
+	if slug.IsSlug(text) {
+		return &Zettel{Slug: text}
+	}
+
+	return &Zettel{
+		Slug:  slug.Make(text),
+		Title: text,
+	}
+}
+
 // IsValid checks if file is a zettel and if it exists.
 func (z *Zettel) IsValid(cfg *config.Config) bool {
 	if z.ID != "" {
 		permZettels := fs.List(cfg.PermanentRoot)
 		fleetZettels := fs.List(cfg.FleetRoot)
 		zettels := append(permZettels, fleetZettels...)
+		zettels = append(zettels, fs.List(cfg.ArchiveRoot)...)
 
 		for _, zettel := range zettels {
 			if strings.Contains(zettel, z.ID) {
@@ -47,14 +74,14 @@ func (z *Zettel) IsValid(cfg *config.Config) bool {
 
 	// Verify if the zettel is valid by checking its path
 	return fs.Exists(z.Path) && (strings.Contains(z.Path, cfg.FleetRoot) ||
-		strings.Contains(z.Path, cfg.PermanentRoot))
+		strings.Contains(z.Path, cfg.PermanentRoot) || strings.Contains(z.Path, cfg.ArchiveRoot))
 }
 
 // Read reads a zettel from the disk and gets all the metadata from it. Useful
 // to query data from the file and insert into a database.
 func (z *Zettel) Read(cfg *config.Config) error {
 	if !z.IsValid(cfg) {
-		return fmt.Errorf("error: zettel is not valid")
+		return fmt.Errorf("%w: %s", ErrInvalidZettel, z.Path)
 	}
 
 	lines, err := fs.ReadLines(z.Path)
@@ -76,14 +103,7 @@ func (z *Zettel) Read(cfg *config.Config) error {
 		results := fs.MatchAllSubstrings("[[", "]]", line)
 		for _, result := range results {
 			if _, ok := mapLinks[result]; !ok && result != z.Slug && result != "" {
-				var s = result
-				if !slug.IsSlug(s) {
-					s = slug.Make(s)
-				}
-				link := &Zettel{
-					Slug: s,
-				}
-				links = append(links, link)
+				links = append(links, NewLink(result))
 				mapLinks[result] = true
 			}
 		}
@@ -94,8 +114,13 @@ func (z *Zettel) Read(cfg *config.Config) error {
 	return nil
 }
 
+// Write replaces the file of the zettel with its content, atomically
 func (z *Zettel) Write() error {
-	return fs.Write(z.Path, z.Content)
+	content := z.Content
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return fs.WriteAtomic(z.Path, content)
 }
 
 func (z *Zettel) WriteLine(line string) error {
@@ -103,6 +128,27 @@ func (z *Zettel) WriteLine(line string) error {
 	return z.Write()
 }
 
+// FirstParagraph returns the first paragraph of the content, after the title
+func (z *Zettel) FirstParagraph() string {
+	lines := strings.Split(z.Content, "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "# ") {
+		lines = lines[1:]
+	}
+
+	var paragraph []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			if len(paragraph) > 0 {
+				break
+			}
+			continue
+		}
+		paragraph = append(paragraph, line)
+	}
+
+	return strings.Join(paragraph, "\n")
+}
+
 func (z *Zettel) IsEqual(z2 *Zettel) bool {
 	return z.ID == z2.ID && z.Title == z2.Title && z.Content == z2.Content && z.Path == z2.Path && z.Type == z2.Type
 }
@@ -119,6 +165,8 @@ func (z *Zettel) readType(cfg *config.Config) string {
 		typ = "fleet"
 	} else if strings.Contains(z.Path, cfg.PermanentRoot) {
 		typ = "permanent"
+	} else if strings.Contains(z.Path, cfg.ArchiveRoot) {
+		typ = "archive"
 	}
 	return typ
 }
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/odas0r/zet/internal/model"
+)
+
+// Dump returns every zettel with its links, events, aliases and review,
+// ordered by creation.
+// The paths are relative to the root.
+func (zr *zettelRepository) Dump(ctx context.Context) ([]*model.Dump, error) {
+	zettels := []*model.Zettel{}
+	err := zr.DB.DB.SelectContext(ctx, &zettels, `select * from zettel order by created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+
+	links := []*model.Link{}
+	err = zr.DB.DB.SelectContext(ctx, &links, `select * from link order by zettel_id, link_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*model.Event{}
+	err = zr.DB.DB.SelectContext(ctx, &events, `select * from event order by created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+
+	type alias struct {
+		Slug     string `db:"slug"`
+		ZettelID string `db:"zettel_id"`
+	}
+
+	aliases := []*alias{}
+	err = zr.DB.DB.SelectContext(ctx, &aliases, `select slug, zettel_id from alias order by created_at, slug`)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews := []*model.Review{}
+	err = zr.DB.DB.SelectContext(ctx, &reviews, `select * from review order by zettel_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	dumps := make([]*model.Dump, len(zettels))
+	byID := make(map[string]*model.Dump, len(zettels))
+	for i, zet := range zettels {
+		dumps[i] = &model.Dump{
+			ID:        zet.ID,
+			Slug:      zet.Slug,
+			Title:     zet.Title,
+			Path:      zr.relativePath(zet.Path),
+			Type:      zet.Type,
+			CreatedAt: zet.CreatedAt,
+			UpdatedAt: zet.UpdatedAt,
+			Content:   zet.Content,
+			Links:     []string{},
+			Tags:      zet.Tags(),
+		}
+		byID[zet.ID] = dumps[i]
+	}
+
+	for _, link := range links {
+		if d, ok := byID[link.From]; ok {
+			d.Links = append(d.Links, link.To)
+		}
+	}
+
+	for _, e := range events {
+		if d, ok := byID[e.ZettelID]; ok {
+			d.Events = append(d.Events, &model.DumpEvent{Kind: e.Kind, Session: e.Session, CreatedAt: e.CreatedAt})
+		}
+	}
+
+	for _, a := range aliases {
+		if d, ok := byID[a.ZettelID]; ok {
+			d.Aliases = append(d.Aliases, a.Slug)
+		}
+	}
+
+	for _, r := range reviews {
+		if d, ok := byID[r.ZettelID]; ok {
+			d.Review = &model.DumpReview{
+				Repetitions: r.Repetitions,
+				Interval:    r.Interval,
+				Ease:        r.Ease,
+				DueAt:       r.DueAt,
+				ReviewedAt:  r.ReviewedAt,
+				CreatedAt:   r.CreatedAt,
+				UpdatedAt:   r.UpdatedAt,
+			}
+		}
+	}
+
+	return dumps, nil
+}
+
+// LoadDump inserts the zettels of a dump, keeping their ids, slugs and
+// timestamps, with their links, history, aliases and reviews, in one
+// transaction. It
+// fails with ErrZettelConflict if any of the zettels already exists, and with
+// ErrInvalidZettel if a path is outside of the fleet, permanent and archive
+// roots. The files are created by the given function, with the absolute
+// paths, before the commit; nothing is inserted when it fails.
+func (zr *zettelRepository) LoadDump(ctx context.Context, dumps []*model.Dump, files func([]*model.Zettel) error) ([]*model.Zettel, error) {
+	tx, err := zr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	zettels := make([]*model.Zettel, len(dumps))
+	for i, d := range dumps {
+		var exists bool
+		err := tx.Tx.GetContext(ctx, &exists, `select exists(select 1 from zettel where id = ? or slug = ?)`, d.ID, d.Slug)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, fmt.Errorf("%w: %s (%s)", ErrZettelConflict, d.ID, d.Slug)
+		}
+
+		path, err := zr.absolutePath(d.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		zet := &model.Zettel{
+			ID:      d.ID,
+			Slug:    d.Slug,
+			Title:   d.Title,
+			Path:    path,
+			Type:    d.Type,
+			Content: d.Content,
+		}
+
+		_, err = tx.Tx.ExecContext(ctx, `
+		insert into zettel (id, title, slug, path, type, content, created_at, updated_at)
+		values (?, ?, ?, ?, ?, ?, ?, ?)
+		`, zet.ID, zet.Title, zet.Slug, zet.Path, zet.Type, zet.Content, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		zettels[i] = zet
+	}
+
+	for _, d := range dumps {
+		for _, to := range d.Links {
+			_, err := tx.Tx.ExecContext(ctx, `
+			insert into link (zettel_id, link_id) values (?, ?)
+			on conflict (zettel_id, link_id) do nothing
+			`, d.ID, to)
+			if err != nil {
+				return nil, fmt.Errorf("error: failed to link %s to %s: %w", d.ID, to, err)
+			}
+		}
+
+		for _, e := range d.Events {
+			_, err := tx.Tx.ExecContext(ctx, `
+			insert into event (zettel_id, kind, session, created_at) values (?, ?, ?, ?)
+			`, d.ID, e.Kind, e.Session, &e.CreatedAt)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, slug := range d.Aliases {
+			_, err := tx.Tx.ExecContext(ctx, `
+			insert into alias (slug, zettel_id) values (?, ?)
+			on conflict (slug) do update set zettel_id = excluded.zettel_id
+			`, slug, d.ID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if r := d.Review; r != nil {
+			// a nil *model.Time cannot be a value
+			var reviewedAt any
+			if r.ReviewedAt != nil {
+				reviewedAt = r.ReviewedAt
+			}
+
+			_, err := tx.Tx.ExecContext(ctx, `
+			insert into review (zettel_id, repetitions, interval, ease, due_at, reviewed_at, created_at, updated_at)
+			values (?, ?, ?, ?, ?, ?, ?, ?)
+			`, d.ID, r.Repetitions, r.Interval, r.Ease, &r.DueAt, reviewedAt, &r.CreatedAt, &r.UpdatedAt)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, zet := range zettels {
+		if err := tx.Tx.GetContext(ctx, zet, `select * from zettel where id = ?`, zet.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := files(zettels); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return zettels, nil
+}
+
+// relativePath returns the path relative to the root, paths outside of it are
+// kept as they are
+func (zr *zettelRepository) relativePath(path string) string {
+	rel, err := filepath.Rel(zr.config.Root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// absolutePath returns the path of a dump inside the root, which must be in
+// the fleet, permanent or archive roots
+func (zr *zettelRepository) absolutePath(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("%w: %s is not relative to the root", ErrInvalidZettel, path)
+	}
+
+	abs := filepath.Join(zr.config.Root, filepath.FromSlash(path))
+	for _, root := range []string{zr.config.FleetRoot, zr.config.PermanentRoot, zr.config.ArchiveRoot} {
+		rel, err := filepath.Rel(root, abs)
+		// a file directly inside of the root
+		if err == nil && filepath.Dir(rel) == "." && rel != "." && rel != ".." {
+			return abs, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s is outside of the zettel roots", ErrInvalidZettel, path)
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/odas0r/zet/internal/model"
+)
+
+func (zr *zettelRepository) InsertEvent(ctx context.Context, zet *model.Zettel, kind string) error {
+	if zet.ID == "" {
+		return ErrNoZettel
+	}
+	if !validKind(kind) {
+		return fmt.Errorf("%w: %s", ErrInvalidKind, kind)
+	}
+
+	_, err := zr.DB.DB.ExecContext(ctx, `
+	insert into event (zettel_id, kind, session) values (?, ?, ?)
+	`, zet.ID, kind, zr.config.Session)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveEvents deletes the history of the zettel, for the zettels that are
+// discarded right after they are created
+func (zr *zettelRepository) RemoveEvents(ctx context.Context, zet *model.Zettel) error {
+	if zet.ID == "" {
+		return ErrNoZettel
+	}
+
+	_, err := zr.DB.DB.ExecContext(ctx, `delete from event where zettel_id = ?`, zet.ID)
+	return err
+}
+
+// Events returns the history matching the filter, most recent first. The
+// events of the removed zettels keep the title they had in the trash.
+func (zr *zettelRepository) Events(ctx context.Context, filter *model.EventFilter) ([]*model.Event, error) {
+	since, kind, limit, err := eventFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	select e.*, coalesce(z.title, t.title, '') as title from event as e
+	left join zettel as z on e.zettel_id = z.id
+	left join trash as t on e.zettel_id = t.id
+	where e.created_at >= ? and (? = '' or e.kind = ?)
+	order by e.created_at desc, e.id desc limit ?
+	`
+
+	events := []*model.Event{}
+	err = zr.DB.DB.SelectContext(ctx, &events, query, since, kind, kind, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (zr *zettelRepository) Recent(ctx context.Context, filter *model.EventFilter) ([]*model.Zettel, error) {
+	since, kind, limit, err := eventFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// the ids break the ties of events in the same millisecond
+	query := `
+	select z.* from zettel as z
+	inner join (
+		select zettel_id, max(created_at) as last_at, max(id) as last_id from event
+		where created_at >= ? and (? = '' or kind = ?)
+		group by zettel_id
+	) as e on e.zettel_id = z.id
+	order by e.last_at desc, e.last_id desc limit ?
+	`
+
+	zettels := []*model.Zettel{}
+	err = zr.DB.DB.SelectContext(ctx, &zettels, query, since, kind, kind, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return zettels, nil
+}
+
+// eventFilter returns the query arguments of the filter, a negative limit is
+// no limit for sqlite
+func eventFilter(filter *model.EventFilter) (*model.Time, string, int, error) {
+	if filter == nil {
+		filter = &model.EventFilter{}
+	}
+
+	if filter.Kind != "" && !validKind(filter.Kind) {
+		return nil, "", 0, fmt.Errorf("%w: %s", ErrInvalidKind, filter.Kind)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+
+	return &model.Time{T: filter.Since}, filter.Kind, limit, nil
+}
+
+func validKind(kind string) bool {
+	for _, k := range model.EventKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/odas0r/zet/internal/config"
+	"github.com/odas0r/zet/pkg/fs"
+	"github.com/oklog/ulid/v2"
+)
+
+// maxAttempts is the number of candidates tried before giving up on finding
+// a free id
+const maxAttempts = 10000
+
+var ErrNoFreeID = errors.New("error: could not find a free zettel id")
+
+// IDGenerator generates the ids of new zettels. The repository keeps asking
+// for candidates, increasing attempt, until it finds one that is not used by
+// any zettel in the database or in the filesystem.
+type IDGenerator interface {
+	Generate(parent string, attempt int) (string, error)
+	Valid(id string) bool
+}
+
+// NewIDGenerator returns the generator for the given config.IDFormat
+func NewIDGenerator(format string) (IDGenerator, error) {
+	switch format {
+	case config.IDFormatTimestamp, "":
+		return &timestampGenerator{now: time.Now}, nil
+	case config.IDFormatULID:
+		return &ulidGenerator{}, nil
+	case config.IDFormatFolgezettel:
+		return &folgezettelGenerator{}, nil
+	default:
+		return nil, fmt.Errorf("error: unknown id format %q", format)
+	}
+}
+
+// timestampGenerator generates ids like 20220605165935123. Candidates that
+// collide are moved forward by one millisecond per attempt.
+type timestampGenerator struct {
+	now func() time.Time
+}
+
+var timestampRe = regexp.MustCompile(`^\d{14}(\d{3})?$`)
+
+func (g *timestampGenerator) Generate(_ string, attempt int) (string, error) {
+	t := g.now().Add(time.Duration(attempt) * time.Millisecond)
+	return strings.Replace(t.Format("20060102150405.000"), ".", "", 1), nil
+}
+
+// Valid accepts both the old second resolution ids and the new ones
+func (g *timestampGenerator) Valid(id string) bool {
+	return timestampRe.MatchString(id)
+}
+
+type ulidGenerator struct{}
+
+var ulidRe = regexp.MustCompile(`^[0-9a-hjkmnp-tv-z]{26}$`)
+
+func (g *ulidGenerator) Generate(_ string, _ int) (string, error) {
+	// lowercase, filenames and slugs are lowercase
+	return strings.ToLower(ulid.Make().String()), nil
+}
+
+func (g *ulidGenerator) Valid(id string) bool {
+	return ulidRe.MatchString(id)
+}
+
+// folgezettelGenerator generates ids like 1, 1a, 1a1. Without a parent the
+// candidates are the top level numbers, with a parent they are its children,
+// alternating between numbers and letters.
+type folgezettelGenerator struct{}
+
+var folgezettelRe = regexp.MustCompile(`^([1-9]\d*)([a-z]+[1-9]\d*)*[a-z]*$`)
+
+func (g *folgezettelGenerator) Generate(parent string, attempt int) (string, error) {
+	if parent == "" {
+		return strconv.Itoa(attempt + 1), nil
+	}
+
+	if !g.Valid(parent) {
+		return "", fmt.Errorf("error: invalid folgezettel parent %q", parent)
+	}
+
+	last := parent[len(parent)-1]
+	if last >= '0' && last <= '9' {
+		return parent + letters(attempt), nil
+	}
+
+	return parent + strconv.Itoa(attempt+1), nil
+}
+
+func (g *folgezettelGenerator) Valid(id string) bool {
+	return folgezettelRe.MatchString(id)
+}
+
+// letters returns a, b, ..., z, aa, ab, ... for 0, 1, ..., 25, 26, 27, ...
+func letters(n int) string {
+	s := ""
+	for n >= 0 {
+		s = string(rune('a'+n%26)) + s
+		n = n/26 - 1
+	}
+	return s
+}
+
+func (zr *zettelRepository) NextID(ctx context.Context, parent string) (string, error) {
+	return zr.nextID(ctx, parent, nil)
+}
+
+// nextID asks the generator for candidates until one is free in the database,
+// in the trash, in the filesystem and in taken, which holds the ids of a batch that are not
+// yet saved.
+func (zr *zettelRepository) nextID(ctx context.Context, parent string, taken map[string]bool) (string, error) {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		id, err := zr.ids.Generate(parent, attempt)
+		if err != nil {
+			return "", err
+		}
+
+		if taken[id] {
+			continue
+		}
+
+		var count int
+		err = zr.DB.DB.GetContext(ctx, &count, `
+		select (select count(*) from zettel where id = ?) + (select count(*) from trash where id = ?)
+		`, id, id)
+		if err != nil {
+			return "", err
+		}
+		if count > 0 {
+			continue
+		}
+
+		if fs.Exists(zr.config.FleetRoot+"/"+id+".md") || fs.Exists(zr.config.PermanentRoot+"/"+id+".md") {
+			continue
+		}
+
+		return id, nil
+	}
+
+	return "", ErrNoFreeID
+}
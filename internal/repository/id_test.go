@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+	"github.com/odas0r/zet/internal/config"
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/internal/test/sqltest"
+)
+
+func TestIDGenerator(t *testing.T) {
+	t.Run("timestamp ids have millisecond precision", func(t *testing.T) {
+		now := time.Date(2023, 8, 1, 16, 31, 47, 123*int(time.Millisecond), time.Local)
+		g := &timestampGenerator{now: func() time.Time { return now }}
+
+		id, err := g.Generate("", 0)
+		require.Equal(t, err, nil, "failed to generate id")
+		assert.Equal(t, id, "20230801163147123", "id should have milliseconds")
+
+		id, err = g.Generate("", 2)
+		require.Equal(t, err, nil, "failed to generate id")
+		assert.Equal(t, id, "20230801163147125", "collisions should move forward")
+
+		assert.Equal(t, g.Valid("20230801163147"), true, "second resolution ids are valid")
+		assert.Equal(t, g.Valid("a-slug"), false, "slugs are not valid")
+	})
+
+	t.Run("ulid ids are lowercase", func(t *testing.T) {
+		g := &ulidGenerator{}
+
+		id, err := g.Generate("", 0)
+		require.Equal(t, err, nil, "failed to generate id")
+		assert.Equal(t, len(id), 26, "ulid should have 26 characters")
+		assert.Equal(t, g.Valid(id), true, "ulid should be valid")
+	})
+
+	t.Run("folgezettel ids branch off the parent", func(t *testing.T) {
+		g := &folgezettelGenerator{}
+
+		cases := []struct {
+			parent   string
+			attempt  int
+			expected string
+		}{
+			{"", 0, "1"},
+			{"", 4, "5"},
+			{"1", 0, "1a"},
+			{"1", 27, "1ab"},
+			{"1a", 1, "1a2"},
+			{"1a2", 2, "1a2c"},
+		}
+
+		for _, c := range cases {
+			id, err := g.Generate(c.parent, c.attempt)
+			require.Equal(t, err, nil, "failed to generate id")
+			assert.Equal(t, id, c.expected, "unexpected folgezettel id")
+			assert.Equal(t, g.Valid(id), true, "folgezettel id should be valid")
+		}
+
+		_, err := g.Generate("not-an-id", 0)
+		assert.NotEqual(t, err, nil, "invalid parent should fail")
+	})
+}
+
+func TestZettelRepository_NextID(t *testing.T) {
+	t.Run("does not collide when creating zettels in a row", func(t *testing.T) {
+		db := sqltest.CreateDatabase(t, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
+
+		err = repo.Reset(context.Background())
+		require.Equal(t, err, nil, "failed to reset database")
+
+		ids := make(map[string]bool)
+		for i := 0; i < 20; i++ {
+			zet := &model.Zettel{Title: "Testing Zettel"}
+			createZettel(t, repo, zet)
+			ids[zet.ID] = true
+		}
+
+		var zettels []*model.Zettel
+		for i := 0; i < 20; i++ {
+			zettels = append(zettels, &model.Zettel{Title: "Testing Zettel"})
+		}
+		err = repo.SaveBulk(context.Background(), zettels...)
+		require.Equal(t, err, nil, "failed to create zettels in bulk")
+		for _, zet := range zettels {
+			ids[zet.ID] = true
+		}
+
+		assert.Equal(t, len(ids), 40, "all ids should be unique")
+	})
+
+	t.Run("skips folgezettel ids that are taken", func(t *testing.T) {
+		fcfg := config.New("/tmp/zet-cmd")
+		fcfg.IDFormat = config.IDFormatFolgezettel
+
+		db := sqltest.CreateDatabase(t, fcfg)
+		repo, err := NewZettelRepository(db, fcfg)
+		require.Equal(t, err, nil, "failed to create the repository")
+
+		err = repo.Reset(context.Background())
+		require.Equal(t, err, nil, "failed to reset database")
+
+		createZettel(t, repo, &model.Zettel{Title: "One"})
+		createZettel(t, repo, &model.Zettel{ID: "1a", Title: "One A"})
+
+		id, err := repo.NextID(context.Background(), "")
+		require.Equal(t, err, nil, "failed to get next id")
+		assert.Equal(t, id, "2", "next top level id should be 2")
+
+		id, err = repo.NextID(context.Background(), "1")
+		require.Equal(t, err, nil, "failed to get next id")
+		assert.Equal(t, id, "1b", "next child of 1 should be 1b")
+	})
+	t.Run("reads the format from zet.json and the environment", func(t *testing.T) {
+		root := t.TempDir()
+		err := os.WriteFile(filepath.Join(root, config.File), []byte(`{"idFormat": "folgezettel"}`), 0644)
+		require.Equal(t, err, nil, "failed to write zet.json")
+
+		ucfg := config.New(root)
+		require.Equal(t, ucfg.Load(), nil, "failed to load zet.json")
+		assert.Equal(t, ucfg.IDFormat, config.IDFormatFolgezettel, "the format should come from zet.json")
+
+		t.Setenv(config.IDFormatEnv, config.IDFormatULID)
+		require.Equal(t, ucfg.Load(), nil, "failed to load zet.json")
+		assert.Equal(t, ucfg.IDFormat, config.IDFormatULID, "the environment should win over zet.json")
+
+		t.Setenv(config.IDFormatEnv, "uuid")
+		require.Equal(t, ucfg.Load(), nil, "failed to load zet.json")
+		db := sqltest.CreateDatabase(t, cfg)
+		_, err = NewZettelRepository(db, ucfg)
+		assert.NotEqual(t, err, nil, "unknown formats should be refused")
+	})
+}
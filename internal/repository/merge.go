@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gosimple/slug"
+	"github.com/odas0r/zet/internal/model"
+)
+
+// Merge indexes the merge of src into dst in one transaction. The files are
+// written by the caller, dst and the relinked zettels carry their new content
+// with their resolved links. Their content and links are replaced, the slug
+// and the aliases of src become aliases of dst, and src is moved to the trash.
+func (zr *zettelRepository) Merge(ctx context.Context, src *model.Zettel, dst *model.Zettel, relinked []*model.Zettel) (*model.Trash, error) {
+	if src.ID == "" || dst.ID == "" {
+		return nil, ErrNoZettel
+	}
+	if src.ID == dst.ID {
+		return nil, fmt.Errorf("error: cannot merge %s into itself", src.Slug)
+	}
+
+	tx, err := zr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, zet := range append([]*model.Zettel{dst}, relinked...) {
+		res, err := tx.Tx.ExecContext(ctx, `update zettel set content = ? where id = ?`, zet.Content, zet.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		nr, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if nr == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrZettelNotFound, zet.ID)
+		}
+
+		_, err = tx.Tx.ExecContext(ctx, `delete from link where zettel_id = ?`, zet.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, link := range zet.Links {
+			// unresolved links are not indexed
+			if link.ID == "" || link.ID == zet.ID || link.ID == src.ID {
+				continue
+			}
+
+			_, err := tx.Tx.ExecContext(ctx, `
+			insert into link (zettel_id, link_id) values (?, ?)
+			on conflict (zettel_id, link_id) do nothing
+			`, zet.ID, link.ID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// the aliases of the source follow it, they would be removed on cascade
+	_, err = tx.Tx.ExecContext(ctx, `update alias set zettel_id = ? where zettel_id = ?`, dst.ID, src.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Tx.ExecContext(ctx, `
+	insert into alias (slug, zettel_id) values (?, ?)
+	on conflict (slug) do update set zettel_id = excluded.zettel_id
+	`, src.Slug, dst.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	trash, err := zr.trash(ctx, tx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	links := dst.Links
+	if err := tx.Tx.GetContext(ctx, dst, `select * from zettel where id = ?`, dst.ID); err != nil {
+		return nil, err
+	}
+	dst.Links = links
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return trash, nil
+}
+
+func (zr *zettelRepository) Aliases(ctx context.Context, zet *model.Zettel) ([]string, error) {
+	aliases := []string{}
+	err := zr.DB.DB.SelectContext(ctx, &aliases, `select slug from alias where zettel_id = ? order by created_at, slug`, zet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return aliases, nil
+}
+
+func (zr *zettelRepository) AddAliases(ctx context.Context, zet *model.Zettel, aliases ...string) error {
+	if zet.ID == "" {
+		return ErrNoZettel
+	}
+
+	tx, err := zr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, alias := range aliases {
+		s := slug.Make(alias)
+		if s == "" || s == zet.Slug {
+			continue
+		}
+
+		// the slug of a zettel resolves to it before any alias
+		var count int
+		if err := tx.Tx.GetContext(ctx, &count, `select count(*) from zettel where slug = ?`, s); err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		_, err = tx.Tx.ExecContext(ctx, `insert into alias (slug, zettel_id) values (?, ?) on conflict (slug) do nothing`, s, zet.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// resolveAlias points the link at the zettel its slug is an alias of, it
+// fails with ErrZettelNotFound when it is not an alias
+func (zr *zettelRepository) resolveAlias(ctx context.Context, link *model.Zettel) error {
+	if link.Slug == "" {
+		return ErrZettelNotFound
+	}
+
+	var id string
+	err := zr.DB.DB.GetContext(ctx, &id, `select zettel_id from alias where slug = ?`, link.Slug)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrZettelNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	link.ID = id
+	return zr.Get(ctx, link)
+}
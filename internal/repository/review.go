@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/odas0r/zet/internal/model"
+)
+
+// ScheduleReviews adds the permanent zettels without a schedule to the
+// review queue, due right away. It returns how many were added.
+func (zr *zettelRepository) ScheduleReviews(ctx context.Context) (int, error) {
+	res, err := zr.DB.DB.ExecContext(ctx, `
+	insert into review (zettel_id)
+	select id from zettel
+	where type = 'permanent' and id not in (select zettel_id from review)
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	nr, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(nr), nil
+}
+
+func (zr *zettelRepository) NextReview(ctx context.Context, now time.Time) (*model.Zettel, *model.Review, error) {
+	review := &model.Review{}
+	err := zr.DB.DB.GetContext(ctx, review, `
+	select r.* from review as r
+	inner join zettel as z on z.id = r.zettel_id
+	where z.type = 'permanent' and r.due_at <= ?
+	order by r.due_at asc, r.zettel_id asc limit 1
+	`, &model.Time{T: now})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrNothingDue
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	zet := &model.Zettel{ID: review.ZettelID}
+	if err := zr.Get(ctx, zet); err != nil {
+		return nil, nil, err
+	}
+
+	return zet, review, nil
+}
+
+func (zr *zettelRepository) RateReview(ctx context.Context, id string, rating int, now time.Time) (*model.Review, error) {
+	if rating < model.MinRating || rating > model.MaxRating {
+		return nil, ErrInvalidRating
+	}
+
+	tx, err := zr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var kind string
+	err = tx.Tx.GetContext(ctx, &kind, `select type from zettel where id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrZettelNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if kind != "permanent" {
+		return nil, fmt.Errorf("%w: only permanent zettels are reviewed", ErrInvalidZettel)
+	}
+
+	review := &model.Review{}
+	err = tx.Tx.GetContext(ctx, review, `select * from review where zettel_id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrZettelNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	review.Rate(rating, now)
+
+	_, err = tx.Tx.ExecContext(ctx, `
+	update review
+	set repetitions = ?, interval = ?, ease = ?, due_at = ?, reviewed_at = ?
+	where zettel_id = ?
+	`, review.Repetitions, review.Interval, review.Ease, &review.DueAt, review.ReviewedAt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Tx.GetContext(ctx, review, `select * from review where zettel_id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+func (zr *zettelRepository) ReviewStats(ctx context.Context, now time.Time) (*model.ReviewStats, error) {
+	// the end of the local day
+	y, m, d := now.Date()
+	endOfDay := time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+
+	stats := &model.ReviewStats{}
+	err := zr.DB.DB.GetContext(ctx, stats, `
+	select
+		count(*) filter (where r.due_at <= ?) as due,
+		count(*) filter (where r.due_at < ?) as due_today,
+		count(*) as total
+	from review as r
+	inner join zettel as z on z.id = r.zettel_id
+	where z.type = 'permanent'
+	`, &model.Time{T: now}, &model.Time{T: endOfDay})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
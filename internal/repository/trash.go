@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/odas0r/zet/internal/model"
+	"github.com/odas0r/zet/pkg/database"
+	"github.com/odas0r/zet/pkg/fs"
+)
+
+func (zr *zettelRepository) Trash(ctx context.Context, zet *model.Zettel) (*model.Trash, error) {
+	if zet.ID == "" {
+		return nil, ErrNoZettel
+	}
+
+	tx, err := zr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	trash, err := zr.trash(ctx, tx, zet)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return trash, nil
+}
+
+// trash moves the zettel to the trash within the transaction, see Trash
+func (zr *zettelRepository) trash(ctx context.Context, tx *database.Transaction, zet *model.Zettel) (*model.Trash, error) {
+	trashPath := zr.config.TrashRoot + "/" + zet.ID + ".md"
+
+	res, err := tx.Tx.ExecContext(ctx, `
+	insert into trash (id, title, slug, path, type, content, trash_path, created_at, updated_at)
+	select id, title, slug, path, type, content, ?, created_at, updated_at
+	from zettel where id = ?
+	`, trashPath, zet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	nr, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if nr == 0 {
+		return nil, ErrZettelNotFound
+	}
+
+	// keep the links in both directions
+	_, err = tx.Tx.ExecContext(ctx, `
+	insert into trash_link (zettel_id, link_id, created_at)
+	select zettel_id, link_id, created_at from link
+	where zettel_id = ? or link_id = ?
+	on conflict (zettel_id, link_id) do nothing
+	`, zet.ID, zet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// and the review schedule
+	_, err = tx.Tx.ExecContext(ctx, `
+	insert into trash_review (zettel_id, repetitions, interval, ease, due_at, reviewed_at, created_at, updated_at)
+	select zettel_id, repetitions, interval, ease, due_at, reviewed_at, created_at, updated_at
+	from review where zettel_id = ?
+	on conflict (zettel_id) do nothing
+	`, zet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// links, history and review are removed on cascade
+	_, err = tx.Tx.ExecContext(ctx, `delete from zettel where id = ?`, zet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	trash := &model.Trash{}
+	err = tx.Tx.GetContext(ctx, trash, `select * from trash where id = ?`, zet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return trash, nil
+}
+
+// Restore puts the trashed zettel back in the database with the links whose
+// other end still exists. Links to zettels that are still in the trash are
+// kept, so they come back once those are restored as well.
+func (zr *zettelRepository) Restore(ctx context.Context, id string) (*model.Trash, error) {
+	trash := &model.Trash{}
+	err := zr.DB.DB.GetContext(ctx, trash, `select * from trash where id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrZettelNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var count int
+	err = zr.DB.DB.GetContext(ctx, &count, `select count(*) from zettel where id = ? or path = ?`, trash.ID, trash.Path)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 || fs.Exists(trash.Path) {
+		return nil, fmt.Errorf("%w: %s", ErrZettelConflict, trash.Path)
+	}
+
+	// the slug might have been taken in the meantime
+	s, err := zr.uniqueSlug(ctx, trash.ID, trash.Slug)
+	if err != nil {
+		return nil, err
+	}
+	trash.Slug = s
+
+	tx, err := zr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Tx.ExecContext(ctx, `
+	insert into zettel (id, title, slug, path, type, content, created_at, updated_at)
+	select id, title, ?, path, type, content, created_at, updated_at
+	from trash where id = ?
+	`, trash.Slug, trash.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Tx.ExecContext(ctx, `
+	insert into link (zettel_id, link_id, created_at)
+	select zettel_id, link_id, created_at from trash_link
+	where (zettel_id = ? or link_id = ?)
+		and zettel_id in (select id from zettel)
+		and link_id in (select id from zettel)
+	on conflict (zettel_id, link_id) do nothing
+	`, trash.ID, trash.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Tx.ExecContext(ctx, `
+	delete from trash_link
+	where (zettel_id = ? or link_id = ?)
+		and zettel_id in (select id from zettel)
+		and link_id in (select id from zettel)
+	`, trash.ID, trash.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Tx.ExecContext(ctx, `
+	insert into review (zettel_id, repetitions, interval, ease, due_at, reviewed_at, created_at, updated_at)
+	select zettel_id, repetitions, interval, ease, due_at, reviewed_at, created_at, updated_at
+	from trash_review where zettel_id = ?
+	`, trash.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Tx.ExecContext(ctx, `delete from trash_review where zettel_id = ?`, trash.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Tx.ExecContext(ctx, `delete from trash where id = ?`, trash.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return trash, nil
+}
+
+func (zr *zettelRepository) ListTrash(ctx context.Context) ([]*model.Trash, error) {
+	query := `select * from trash order by removed_at desc`
+
+	trash := []*model.Trash{}
+	err := zr.DB.DB.SelectContext(ctx, &trash, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return trash, nil
+}
+
+// EmptyTrash permanently removes the zettels trashed up to the given time,
+// returning them so their files can be removed as well.
+func (zr *zettelRepository) EmptyTrash(ctx context.Context, before time.Time) ([]*model.Trash, error) {
+	cutoff := &model.Time{T: before}
+
+	tx, err := zr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	trash := []*model.Trash{}
+	err = tx.Tx.SelectContext(ctx, &trash, `select * from trash where removed_at <= ?`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Tx.ExecContext(ctx, `
+	delete from trash_link
+	where zettel_id in (select id from trash where removed_at <= ?)
+		or link_id in (select id from trash where removed_at <= ?)
+	`, cutoff, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Tx.ExecContext(ctx, `
+	delete from trash_review where zettel_id in (select id from trash where removed_at <= ?)
+	`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	// their history goes with them
+	_, err = tx.Tx.ExecContext(ctx, `
+	delete from event where zettel_id in (select id from trash where removed_at <= ?)
+	`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Tx.ExecContext(ctx, `delete from trash where removed_at <= ?`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return trash, nil
+}
@@ -2,11 +2,10 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
-	"os"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/gosimple/slug"
@@ -16,17 +15,29 @@ import (
 	"github.com/odas0r/zet/pkg/database"
 )
 
-var counter uint64
-
 // Errors
 var (
-	ErrZettelNotFound = errors.New("error: zettel not found")
-	ErrNoZettel       = errors.New("error: no zettel provided")
+	ErrZettelNotFound  = errors.New("error: zettel not found")
+	ErrNoZettel        = errors.New("error: no zettel provided")
+	ErrZettelAmbiguous = errors.New("error: zettel is ambiguous")
+	ErrZettelConflict  = errors.New("error: zettel already exists")
+	ErrNothingDue      = errors.New("error: no zettel is due for review")
+	ErrInvalidRating   = errors.New("error: rating must be between 1 and 5")
+	ErrInvalidKind     = errors.New("error: invalid event kind")
+	ErrInvalidQuery    = errors.New("error: invalid search query")
+	ErrInvalidZettel   = model.ErrInvalidZettel
 )
 
 type ZettelRepository interface {
 	Get(ctx context.Context, zettel *model.Zettel) error
 
+	// Resolve finds the zettel a [[link]] points to. Links written as a slug
+	// resolve to the zettel with that exact slug, links written as a title
+	// resolve by title and fail with ErrZettelAmbiguous if more than one zettel
+	// shares it. The slugs of merged zettels resolve to the zettel they were
+	// merged into.
+	Resolve(ctx context.Context, link *model.Zettel) error
+
 	// Save works for both fleet and permanent, if you to make a zettel permanent
 	// you just need to update the path :)
 	Save(ctx context.Context, zettel *model.Zettel) error
@@ -37,27 +48,83 @@ type ZettelRepository interface {
 	Remove(ctx context.Context, zettel *model.Zettel) error
 	RemoveBulk(ctx context.Context, zettels ...*model.Zettel) error
 	LastOpened(ctx context.Context, zettel *model.Zettel) error
-	InsertHistory(ctx context.Context, zettel *model.Zettel) error
-	History(ctx context.Context) ([]*model.Zettel, error)
+
+	// InsertEvent appends an event of the given kind to the history, with the
+	// session of the config
+	InsertEvent(ctx context.Context, zettel *model.Zettel, kind string) error
+	RemoveEvents(ctx context.Context, zettel *model.Zettel) error
+	Events(ctx context.Context, filter *model.EventFilter) ([]*model.Event, error)
+	// Recent compacts the history to the zettels with their last event
+	// matching the filter, most recent first
+	Recent(ctx context.Context, filter *model.EventFilter) ([]*model.Zettel, error)
+
 	ListFleet(ctx context.Context) ([]*model.Zettel, error)
 	ListPermanent(ctx context.Context) ([]*model.Zettel, error)
+	// ListArchive returns the archived zettels, which ListAll leaves out
+	ListArchive(ctx context.Context) ([]*model.Zettel, error)
 	ListAll(ctx context.Context) ([]*model.Zettel, error)
 	Backlinks(ctx context.Context, zet *model.Zettel) ([]*model.Zettel, error)
+	ListLinks(ctx context.Context) ([]*model.Link, error)
 	Search(ctx context.Context, query string) ([]*model.Zettel, error)
 	Reset(ctx context.Context) error
+
+	// Consistency checks used by `zet doctor`
+	DuplicateSlugs(ctx context.Context) ([]*model.Zettel, error)
+	OrphanedHistory(ctx context.Context) ([]string, error)
+	RemoveOrphanedHistory(ctx context.Context) error
+	CheckIndex(ctx context.Context) error
+	RebuildIndex(ctx context.Context) error
+
+	// Trash removes the zettel from the database keeping a copy of it and its
+	// links in the trash, so it can be restored later
+	Trash(ctx context.Context, zettel *model.Zettel) (*model.Trash, error)
+	Restore(ctx context.Context, id string) (*model.Trash, error)
+	ListTrash(ctx context.Context) ([]*model.Trash, error)
+	EmptyTrash(ctx context.Context, before time.Time) ([]*model.Trash, error)
+
+	// Merge indexes the merge of a zettel into another, see `zet merge`.
+	// Aliases are the slugs of the zettels merged into the given one, and the
+	// ones added with AddAliases that no other zettel answers to.
+	Merge(ctx context.Context, src *model.Zettel, dst *model.Zettel, relinked []*model.Zettel) (*model.Trash, error)
+	Aliases(ctx context.Context, zettel *model.Zettel) ([]string, error)
+	AddAliases(ctx context.Context, zettel *model.Zettel, aliases ...string) error
+
+	// NextID returns a free id for a new zettel, in the format of the config.
+	// The parent is only used by the folgezettel format.
+	NextID(ctx context.Context, parent string) (string, error)
+
+	// Dump and LoadDump back up and restore the zettels with their links,
+	// history and reviews, see `zet dump`
+	Dump(ctx context.Context) ([]*model.Dump, error)
+	LoadDump(ctx context.Context, dumps []*model.Dump, files func([]*model.Zettel) error) ([]*model.Zettel, error)
+
+	// Spaced repetition of the permanent zettels, see `zet review`. NextReview
+	// fails with ErrNothingDue when the queue is empty.
+	ScheduleReviews(ctx context.Context) (int, error)
+	NextReview(ctx context.Context, now time.Time) (*model.Zettel, *model.Review, error)
+	RateReview(ctx context.Context, id string, rating int, now time.Time) (*model.Review, error)
+	ReviewStats(ctx context.Context, now time.Time) (*model.ReviewStats, error)
 	Config() *config.Config
 }
 
 type zettelRepository struct {
 	config *config.Config
+	ids    IDGenerator
 	DB     *database.Database
 }
 
-func NewZettelRepository(db *database.Database, config *config.Config) ZettelRepository {
+// NewZettelRepository fails when the id format of the config is unknown
+func NewZettelRepository(db *database.Database, config *config.Config) (ZettelRepository, error) {
+	ids, err := NewIDGenerator(config.IDFormat)
+	if err != nil {
+		return nil, err
+	}
+
 	return &zettelRepository{
 		config: config,
+		ids:    ids,
 		DB:     db,
-	}
+	}, nil
 }
 
 func (zr *zettelRepository) Config() *config.Config {
@@ -118,6 +185,47 @@ func (zr *zettelRepository) Get(c context.Context, zettel *model.Zettel) error {
 	return nil
 }
 
+func (zr *zettelRepository) Resolve(ctx context.Context, link *model.Zettel) error {
+	err := zr.resolve(ctx, link)
+	if errors.Is(err, ErrZettelNotFound) {
+		// the slug of a zettel merged into another one
+		if aliasErr := zr.resolveAlias(ctx, link); !errors.Is(aliasErr, ErrZettelNotFound) {
+			return aliasErr
+		}
+	}
+
+	return err
+}
+
+func (zr *zettelRepository) resolve(ctx context.Context, link *model.Zettel) error {
+	if link.Title == "" {
+		return zr.Get(ctx, link)
+	}
+
+	query := `select * from zettel where title = ? collate nocase order by created_at, id`
+
+	zettels := []*model.Zettel{}
+	err := zr.DB.DB.SelectContext(ctx, &zettels, query, link.Title)
+	if err != nil {
+		return err
+	}
+
+	switch len(zettels) {
+	case 0:
+		// the title might have been changed, fallback to the slug
+		return zr.Get(ctx, link)
+	case 1:
+		link.ID = zettels[0].ID
+		return zr.Get(ctx, link)
+	default:
+		slugs := make([]string, len(zettels))
+		for i, zet := range zettels {
+			slugs[i] = zet.Slug
+		}
+		return fmt.Errorf("%w: [[%s]] matches %s", ErrZettelAmbiguous, link.Title, strings.Join(slugs, ", "))
+	}
+}
+
 func (zr *zettelRepository) Save(ctx context.Context, z *model.Zettel) error {
 	query := `
   insert into zettel (id, title, slug, content, type, path)
@@ -129,13 +237,17 @@ func (zr *zettelRepository) Save(ctx context.Context, z *model.Zettel) error {
 
 	// Set the zettel default values
 	if z.Title == "" {
-		return errors.New("error: title cannot be empty")
+		return fmt.Errorf("%w: title cannot be empty", ErrInvalidZettel)
 	}
 	if z.Slug == "" {
 		z.Slug = slug.Make(z.Title)
 	}
 	if z.ID == "" {
-		z.ID = isosec()
+		id, err := zr.NextID(ctx, "")
+		if err != nil {
+			return err
+		}
+		z.ID = id
 	}
 	if z.Path == "" {
 		z.Path = zr.config.FleetRoot + "/" + z.ID + ".md"
@@ -148,6 +260,12 @@ func (zr *zettelRepository) Save(ctx context.Context, z *model.Zettel) error {
 		z.Type = "fleet"
 	}
 
+	s, err := zr.stableSlug(ctx, z)
+	if err != nil {
+		return err
+	}
+	z.Slug = s
+
 	rows, err := zr.DB.DB.NamedQueryContext(ctx, query, z)
 	if err != nil {
 		return err
@@ -164,17 +282,44 @@ func (zr *zettelRepository) Save(ctx context.Context, z *model.Zettel) error {
 }
 
 func (zr *zettelRepository) SaveBulk(ctx context.Context, zettels ...*model.Zettel) error {
+	type owner struct {
+		ID    string `db:"id"`
+		Title string `db:"title"`
+		Slug  string `db:"slug"`
+	}
+
+	owners := []*owner{}
+	if err := zr.DB.DB.SelectContext(ctx, &owners, `select id, title, slug from zettel`); err != nil {
+		return err
+	}
+
+	// slug -> id, zettels already in the database keep their slugs
+	taken := make(map[string]string, len(owners))
+	byID := make(map[string]*owner, len(owners))
+	for _, o := range owners {
+		taken[o.Slug] = o.ID
+		byID[o.ID] = o
+	}
+
+	// ids of the batch, they are not in the database yet
+	ids := make(map[string]bool, len(zettels))
+
 	// Set the zettel default values
 	for _, z := range zettels {
 		if z.Title == "" {
-			return errors.New("error: title cannot be empty")
+			return fmt.Errorf("%w: title cannot be empty", ErrInvalidZettel)
 		}
 		if z.Slug == "" {
 			z.Slug = slug.Make(z.Title)
 		}
 		if z.ID == "" {
-			z.ID = isosec()
+			id, err := zr.nextID(ctx, "", ids)
+			if err != nil {
+				return err
+			}
+			z.ID = id
 		}
+		ids[z.ID] = true
 		if z.Path == "" {
 			z.Path = zr.config.FleetRoot + "/" + z.ID + ".md"
 		}
@@ -184,6 +329,17 @@ func (zr *zettelRepository) SaveBulk(ctx context.Context, zettels ...*model.Zett
 		if z.Type == "" {
 			z.Type = "fleet"
 		}
+
+		// the slug only changes with the title, a -2 suffix is kept
+		if o, ok := byID[z.ID]; ok && o.Title == z.Title {
+			z.Slug = o.Slug
+		}
+
+		base := z.Slug
+		for i := 2; taken[z.Slug] != "" && taken[z.Slug] != z.ID; i++ {
+			z.Slug = fmt.Sprintf("%s-%d", base, i)
+		}
+		taken[z.Slug] = z.ID
 	}
 
 	query := `
@@ -327,9 +483,9 @@ func (zr *zettelRepository) RemoveBulk(ctx context.Context, zettels ...*model.Ze
 
 func (zr *zettelRepository) LastOpened(ctx context.Context, zettel *model.Zettel) error {
 	query := `
-	select z.* from history as h
-	inner join zettel as z on h.zettel_id = z.id
-	order by h.updated_at desc limit 1
+	select z.* from event as e
+	inner join zettel as z on e.zettel_id = z.id
+	order by e.created_at desc, e.id desc limit 1
 	`
 
 	err := zr.DB.DB.GetContext(ctx, zettel, query)
@@ -340,27 +496,8 @@ func (zr *zettelRepository) LastOpened(ctx context.Context, zettel *model.Zettel
 	return nil
 }
 
-func (zr *zettelRepository) InsertHistory(ctx context.Context, zet *model.Zettel) error {
-	query := `
-  insert into history (zettel_id)
-  values (?) on conflict (zettel_id) do update
-  set updated_at = strftime('%Y-%m-%dT%H:%M:%fZ')
-  `
-
-	_, err := zr.DB.DB.ExecContext(ctx, query, zet.ID)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (zr *zettelRepository) History(ctx context.Context) ([]*model.Zettel, error) {
-	query := `
-  select z.* from zettel as z
-  inner join history as h on z.id = h.zettel_id
-  order by h.updated_at desc limit 50
-  `
+func (zr *zettelRepository) ListFleet(ctx context.Context) ([]*model.Zettel, error) {
+	query := `select * from zettel where type = 'fleet' order by updated_at desc`
 
 	zettels := []*model.Zettel{}
 	err := zr.DB.DB.SelectContext(ctx, &zettels, query)
@@ -371,8 +508,8 @@ func (zr *zettelRepository) History(ctx context.Context) ([]*model.Zettel, error
 	return zettels, nil
 }
 
-func (zr *zettelRepository) ListFleet(ctx context.Context) ([]*model.Zettel, error) {
-	query := `select * from zettel where type = 'fleet' order by updated_at desc`
+func (zr *zettelRepository) ListPermanent(ctx context.Context) ([]*model.Zettel, error) {
+	query := `select * from zettel where type = 'permanent' order by updated_at desc`
 
 	zettels := []*model.Zettel{}
 	err := zr.DB.DB.SelectContext(ctx, &zettels, query)
@@ -383,8 +520,8 @@ func (zr *zettelRepository) ListFleet(ctx context.Context) ([]*model.Zettel, err
 	return zettels, nil
 }
 
-func (zr *zettelRepository) ListPermanent(ctx context.Context) ([]*model.Zettel, error) {
-	query := `select * from zettel where type = 'permanent' order by updated_at desc`
+func (zr *zettelRepository) ListArchive(ctx context.Context) ([]*model.Zettel, error) {
+	query := `select * from zettel where type = 'archive' order by updated_at desc`
 
 	zettels := []*model.Zettel{}
 	err := zr.DB.DB.SelectContext(ctx, &zettels, query)
@@ -413,6 +550,12 @@ func (zr *zettelRepository) Reset(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+
+	// the events are not removed on cascade
+	_, err = zr.DB.DB.ExecContext(ctx, `delete from event`)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -456,6 +599,18 @@ func (zr *zettelRepository) Backlinks(ctx context.Context, zet *model.Zettel) ([
 	return zettels, nil
 }
 
+func (zr *zettelRepository) ListLinks(ctx context.Context) ([]*model.Link, error) {
+	query := `select * from link order by zettel_id, link_id`
+
+	links := []*model.Link{}
+	err := zr.DB.DB.SelectContext(ctx, &links, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
 func (zr *zettelRepository) Search(ctx context.Context, query string) ([]*model.Zettel, error) {
 	q := `
 	select
@@ -467,13 +622,32 @@ func (zr *zettelRepository) Search(ctx context.Context, query string) ([]*model.
 		z.created_at,
 	  z.updated_at
 	from zettel z
-	  join zettel_fts zf on (zf.rowid = z.id)
+	  join zettel_fts zf on (zf.rowid = z.rowid)
 	where zettel_fts match ?
 	order by rank
 	`
 
 	var zettels []*model.Zettel
 	err := zr.DB.DB.SelectContext(ctx, &zettels, q, query)
+	if database.IsQueryError(err) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return zettels, nil
+}
+
+func (zr *zettelRepository) DuplicateSlugs(ctx context.Context) ([]*model.Zettel, error) {
+	query := `
+	select * from zettel
+	where slug in (select slug from zettel group by slug having count(*) > 1)
+	order by slug, created_at
+	`
+
+	zettels := []*model.Zettel{}
+	err := zr.DB.DB.SelectContext(ctx, &zettels, query)
 	if err != nil {
 		return nil, err
 	}
@@ -481,6 +655,104 @@ func (zr *zettelRepository) Search(ctx context.Context, query string) ([]*model.
 	return zettels, nil
 }
 
+// OrphanedHistory returns the zettel ids of the events that no longer point
+// to a zettel, neither in the database nor in the trash. The events of the
+// trashed zettels are removed when the trash is emptied, this only happens
+// when a zettel is removed without going through the trash.
+func (zr *zettelRepository) OrphanedHistory(ctx context.Context) ([]string, error) {
+	query := `
+	select distinct e.zettel_id from event as e
+	left join zettel as z on e.zettel_id = z.id
+	left join trash as t on e.zettel_id = t.id
+	where z.id is null and t.id is null
+	`
+
+	ids := []string{}
+	err := zr.DB.DB.SelectContext(ctx, &ids, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (zr *zettelRepository) RemoveOrphanedHistory(ctx context.Context) error {
+	query := `
+	delete from event
+	where zettel_id not in (select id from zettel) and zettel_id not in (select id from trash)
+	`
+
+	_, err := zr.DB.DB.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CheckIndex runs the fts5 integrity check, comparing the full text index
+// against the zettel table. It returns an error if both have drifted apart.
+func (zr *zettelRepository) CheckIndex(ctx context.Context) error {
+	query := `insert into zettel_fts(zettel_fts, rank) values ('integrity-check', 1)`
+
+	_, err := zr.DB.DB.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RebuildIndex discards the full text index and rebuilds it from the zettel
+// table.
+func (zr *zettelRepository) RebuildIndex(ctx context.Context) error {
+	query := `insert into zettel_fts(zettel_fts) values ('rebuild')`
+
+	_, err := zr.DB.DB.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// stableSlug returns the slug the zettel is saved with, the one it already has
+// while its title does not change, otherwise a unique one, see uniqueSlug. So
+// a -2 suffix is kept on every save even when the bare slug is freed.
+func (zr *zettelRepository) stableSlug(ctx context.Context, z *model.Zettel) (string, error) {
+	var saved struct {
+		Title string `db:"title"`
+		Slug  string `db:"slug"`
+	}
+	err := zr.DB.DB.GetContext(ctx, &saved, `select title, slug from zettel where id = ?`, z.ID)
+	if err == nil && saved.Title == z.Title {
+		return saved.Slug, nil
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	return zr.uniqueSlug(ctx, z.ID, z.Slug)
+}
+
+// uniqueSlug returns the given slug if it is free or already owned by the
+// zettel with the given id, otherwise it appends a -2, -3, ... suffix until it
+// finds one that is.
+func (zr *zettelRepository) uniqueSlug(ctx context.Context, id string, base string) (string, error) {
+	s := base
+	for i := 2; ; i++ {
+		var owner string
+		err := zr.DB.DB.GetContext(ctx, &owner, `select id from zettel where slug = ?`, s)
+		if errors.Is(err, sql.ErrNoRows) || owner == id {
+			return s, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		s = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
 // emptyContent returns an empty content for a zettel, which has the following
 // structure:
 // # <title>
@@ -489,14 +761,3 @@ func (zr *zettelRepository) Search(ctx context.Context, query string) ([]*model.
 func emptyContent(title string) string {
 	return "# " + title + "\n\n\n"
 }
-
-// isosec generates now timestamps like 20220605165935(0-99999) using the atomic
-// package to generate id's, avoiding collisions
-func isosec() string {
-	// if is in test mode, add counter to avoid collisions
-	if os.Getenv("TEST") == "true" {
-		return fmt.Sprintf("%s%01d", time.Now().Format("20060102150405"), atomic.AddUint64(&counter, 1)%100000)
-	}
-
-	return time.Now().Format("20060102150405")
-}
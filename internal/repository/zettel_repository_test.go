@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 
@@ -17,7 +18,8 @@ var cfg = config.New("/tmp/zet-cmd")
 func TestZettelRepository_Get(t *testing.T) {
 	t.Run("can get a zettel", func(t *testing.T) {
 		db := sqltest.CreateDatabase(t, cfg)
-		repo := NewZettelRepository(db, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
 
 		z1 := &model.Zettel{
 			ID:    "4",
@@ -36,7 +38,7 @@ func TestZettelRepository_Get(t *testing.T) {
 		repo.Save(context.Background(), z2)
 		repo.Save(context.Background(), z3)
 
-		err := repo.Link(context.Background(), z1, []*model.Zettel{z2, z3})
+		err = repo.Link(context.Background(), z1, []*model.Zettel{z2, z3})
 		require.Equal(t, err, nil, "failed to link zettels")
 
 		err = repo.Get(context.Background(), z1)
@@ -54,7 +56,8 @@ func TestZettelRepository_Get(t *testing.T) {
 func TestZettelRepository_Create(t *testing.T) {
 	t.Run("can create a zettel", func(t *testing.T) {
 		db := sqltest.CreateDatabase(t, cfg)
-		repo := NewZettelRepository(db, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
 
 		zettel := &model.Zettel{
 			ID:    "1",
@@ -63,7 +66,7 @@ func TestZettelRepository_Create(t *testing.T) {
 
 		createZettel(t, repo, zettel)
 
-		err := repo.Get(context.Background(), zettel)
+		err = repo.Get(context.Background(), zettel)
 		require.Equal(t, err, nil, "failed to get the zettel from db")
 
 		assert.Equal(t, zettel.Lines[0], "# Testing Zettel", "first line should be the title")
@@ -74,7 +77,8 @@ func TestZettelRepository_Create(t *testing.T) {
 	})
 	t.Run("can create bulk zettel", func(t *testing.T) {
 		db := sqltest.CreateDatabase(t, cfg)
-		repo := NewZettelRepository(db, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
 
 		z1 := &model.Zettel{
 			ID:    "1",
@@ -110,7 +114,7 @@ tempor invidunt ut labore et dolore magna aliquyam
 		var zettels []*model.Zettel
 		zettels = append(zettels, z1, z2, z3)
 
-		err := repo.SaveBulk(context.Background(), zettels...)
+		err = repo.SaveBulk(context.Background(), zettels...)
 		require.Equal(t, err, nil, "failed to create zettels in bulk mode")
 
 		//
@@ -143,7 +147,8 @@ tempor invidunt ut labore et dolore magna aliquyam
 func TestZettelRepository_Link(t *testing.T) {
 	t.Run("can link different zettels", func(t *testing.T) {
 		db := sqltest.CreateDatabase(t, cfg)
-		repo := NewZettelRepository(db, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
 
 		z1 := &model.Zettel{
 			ID:    "1",
@@ -162,7 +167,7 @@ func TestZettelRepository_Link(t *testing.T) {
 		createZettel(t, repo, z2)
 		createZettel(t, repo, z3)
 
-		err := repo.Link(context.Background(), z1, []*model.Zettel{z2, z3})
+		err = repo.Link(context.Background(), z1, []*model.Zettel{z2, z3})
 		require.Equal(t, err, nil, "failed to link zettels")
 
 		err = repo.Get(context.Background(), z1)
@@ -175,7 +180,8 @@ func TestZettelRepository_Link(t *testing.T) {
 
 	t.Run("can unlink different zettels", func(t *testing.T) {
 		db := sqltest.CreateDatabase(t, cfg)
-		repo := NewZettelRepository(db, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
 
 		z1 := &model.Zettel{
 			ID:    "1",
@@ -194,7 +200,7 @@ func TestZettelRepository_Link(t *testing.T) {
 		createZettel(t, repo, z2)
 		createZettel(t, repo, z3)
 
-		err := repo.Link(context.Background(), z1, []*model.Zettel{z2, z3})
+		err = repo.Link(context.Background(), z1, []*model.Zettel{z2, z3})
 		require.Equal(t, err, nil, "failed to link zettels")
 
 		err = repo.Link(context.Background(), z2, []*model.Zettel{z1, z3})
@@ -221,7 +227,8 @@ func TestZettelRepository_Link(t *testing.T) {
 
 	t.Run("can get backlinks", func(t *testing.T) {
 		db := sqltest.CreateDatabase(t, cfg)
-		repo := NewZettelRepository(db, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
 
 		z1 := &model.Zettel{
 			ID:    "1",
@@ -259,7 +266,7 @@ func TestZettelRepository_Link(t *testing.T) {
 			},
 		}
 
-		err := repo.LinkBulk(context.Background(), links...)
+		err = repo.LinkBulk(context.Background(), links...)
 		require.Equal(t, err, nil, "failed to bulk link zettels")
 
 		backlinks, err := repo.Backlinks(context.Background(), z3)
@@ -274,7 +281,8 @@ func TestZettelRepository_Link(t *testing.T) {
 
 	t.Run("can link bulk", func(t *testing.T) {
 		db := sqltest.CreateDatabase(t, cfg)
-		repo := NewZettelRepository(db, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
 
 		z1 := &model.Zettel{
 			ID:    "1",
@@ -312,7 +320,7 @@ func TestZettelRepository_Link(t *testing.T) {
 			},
 		}
 
-		err := repo.LinkBulk(context.Background(), links...)
+		err = repo.LinkBulk(context.Background(), links...)
 		require.Equal(t, err, nil, "failed to bulk link zettels")
 
 		repo.Get(context.Background(), z1)
@@ -324,7 +332,8 @@ func TestZettelRepository_Link(t *testing.T) {
 func TestZettelRepository_Remove(t *testing.T) {
 	t.Run("can remove a zettel", func(t *testing.T) {
 		db := sqltest.CreateDatabase(t, cfg)
-		repo := NewZettelRepository(db, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
 
 		z1 := &model.Zettel{Title: "Testing Zettel"}
 		z2 := &model.Zettel{Title: "Testing Zettel 2"}
@@ -333,7 +342,7 @@ func TestZettelRepository_Remove(t *testing.T) {
 		createZettel(t, repo, z2)
 
 		// Link z2 to z1
-		err := repo.Link(context.Background(), z2, []*model.Zettel{z1})
+		err = repo.Link(context.Background(), z2, []*model.Zettel{z1})
 		require.Equal(t, err, nil, "failed to link zettels")
 
 		// Remove z1
@@ -350,7 +359,8 @@ func TestZettelRepository_Remove(t *testing.T) {
 
 	t.Run("can remove a zettel and its links", func(t *testing.T) {
 		db := sqltest.CreateDatabase(t, cfg)
-		repo := NewZettelRepository(db, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
 
 		zettel := &model.Zettel{
 			ID:    "1",
@@ -359,7 +369,7 @@ func TestZettelRepository_Remove(t *testing.T) {
 
 		createZettel(t, repo, zettel)
 
-		err := repo.Remove(context.Background(), zettel)
+		err = repo.Remove(context.Background(), zettel)
 		require.Equal(t, err, nil, "failed to remove zettel")
 
 		err = repo.Get(context.Background(), zettel)
@@ -368,7 +378,8 @@ func TestZettelRepository_Remove(t *testing.T) {
 
 	t.Run("can remove zettel in bulk", func(t *testing.T) {
 		db := sqltest.CreateDatabase(t, cfg)
-		repo := NewZettelRepository(db, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
 
 		zettels := []*model.Zettel{
 			{
@@ -385,7 +396,7 @@ func TestZettelRepository_Remove(t *testing.T) {
 			},
 		}
 
-		err := repo.SaveBulk(context.Background(), zettels...)
+		err = repo.SaveBulk(context.Background(), zettels...)
 		require.Equal(t, err, nil, "failed to create zettel")
 
 		err = repo.RemoveBulk(context.Background(), zettels...)
@@ -405,9 +416,10 @@ func TestZettelRepository_Remove(t *testing.T) {
 func TestZettelRepository_List(t *testing.T) {
 	t.Run("can list all fleets", func(t *testing.T) {
 		db := sqltest.CreateDatabase(t, cfg)
-		repo := NewZettelRepository(db, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
 
-		err := repo.Reset(context.Background())
+		err = repo.Reset(context.Background())
 		require.Equal(t, err, nil, "failed to reset database")
 
 		zettels := []*model.Zettel{
@@ -436,9 +448,10 @@ func TestZettelRepository_List(t *testing.T) {
 
 	t.Run("can list all permanent ", func(t *testing.T) {
 		db := sqltest.CreateDatabase(t, cfg)
-		repo := NewZettelRepository(db, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
 
-		err := repo.Reset(context.Background())
+		err = repo.Reset(context.Background())
 		require.Equal(t, err, nil, "failed to reset database")
 
 		zettels := []*model.Zettel{
@@ -477,9 +490,10 @@ func TestZettelRepository_List(t *testing.T) {
 
 	t.Run("can list all zettels", func(t *testing.T) {
 		db := sqltest.CreateDatabase(t, cfg)
-		repo := NewZettelRepository(db, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
 
-		err := repo.Reset(context.Background())
+		err = repo.Reset(context.Background())
 		require.Equal(t, err, nil, "failed to reset database")
 
 		zettels := []*model.Zettel{
@@ -552,6 +566,117 @@ func TestZettelRepository_Search(t *testing.T) {
 	})
 }
 
+func TestZettelRepository_Slug(t *testing.T) {
+	t.Run("can disambiguate slugs of zettels with the same title", func(t *testing.T) {
+		db := sqltest.CreateDatabase(t, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
+
+		err = repo.Reset(context.Background())
+		require.Equal(t, err, nil, "failed to reset database")
+
+		z1 := &model.Zettel{ID: "1", Title: "Testing Zettel"}
+		z2 := &model.Zettel{ID: "2", Title: "Testing Zettel"}
+		z3 := &model.Zettel{ID: "3", Title: "Testing Zettel"}
+
+		createZettel(t, repo, z1)
+		createZettel(t, repo, z2)
+
+		err = repo.SaveBulk(context.Background(), z3)
+		require.Equal(t, err, nil, "failed to create zettel in bulk")
+
+		assert.Equal(t, z1.Slug, "testing-zettel", "z1 should keep the bare slug")
+		assert.Equal(t, z2.Slug, "testing-zettel-2", "z2 should get a -2 suffix")
+		assert.Equal(t, z3.Slug, "testing-zettel-3", "z3 should get a -3 suffix")
+
+		// saving again with the bare slug keeps the suffix
+		z2.Slug = "testing-zettel"
+		createZettel(t, repo, z2)
+		assert.Equal(t, z2.Slug, "testing-zettel-2", "z2 should keep its slug")
+
+		// the suffix is kept when the bare slug is freed, links to it still work
+		err = repo.Remove(context.Background(), z1)
+		require.Equal(t, err, nil, "failed to remove z1")
+		z2.Slug = "testing-zettel"
+		createZettel(t, repo, z2)
+		assert.Equal(t, z2.Slug, "testing-zettel-2", "z2 should keep its slug after z1 is removed")
+		z3.Slug = "testing-zettel"
+		err = repo.SaveBulk(context.Background(), z3)
+		require.Equal(t, err, nil, "failed to save zettel in bulk")
+		assert.Equal(t, z3.Slug, "testing-zettel-3", "z3 should keep its slug after z1 is removed")
+
+		// the slug follows the title
+		z2.Title = "Another Zettel"
+		z2.Slug = "another-zettel"
+		createZettel(t, repo, z2)
+		assert.Equal(t, z2.Slug, "another-zettel", "z2 slug should follow its title")
+	})
+
+	t.Run("reports ambiguous links by title", func(t *testing.T) {
+		db := sqltest.CreateDatabase(t, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
+
+		err = repo.Reset(context.Background())
+		require.Equal(t, err, nil, "failed to reset database")
+
+		createZettel(t, repo, &model.Zettel{ID: "1", Title: "Testing Zettel"})
+		createZettel(t, repo, &model.Zettel{ID: "2", Title: "Testing Zettel"})
+		createZettel(t, repo, &model.Zettel{ID: "3", Title: "Another Zettel"})
+
+		link := &model.Zettel{Slug: "testing-zettel", Title: "Testing Zettel"}
+		err = repo.Resolve(context.Background(), link)
+		assert.Equal(t, errors.Is(err, ErrZettelAmbiguous), true, "link should be ambiguous")
+
+		link = &model.Zettel{Slug: "testing-zettel-2"}
+		err = repo.Resolve(context.Background(), link)
+		require.Equal(t, err, nil, "failed to resolve link by slug")
+		assert.Equal(t, link.ID, "2", "link should resolve to z2")
+
+		link = &model.Zettel{Slug: "another-zettel", Title: "another zettel"}
+		err = repo.Resolve(context.Background(), link)
+		require.Equal(t, err, nil, "failed to resolve link by title")
+		assert.Equal(t, link.ID, "3", "link should resolve to z3")
+	})
+}
+
+func TestZettelRepository_Doctor(t *testing.T) {
+	t.Run("has no duplicate slugs", func(t *testing.T) {
+		db := sqltest.CreateDatabase(t, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
+
+		err = repo.Reset(context.Background())
+		require.Equal(t, err, nil, "failed to reset database")
+
+		zettels := []*model.Zettel{
+			{ID: "1", Title: "Testing Zettel"},
+			{ID: "2", Title: "Testing Zettel"},
+		}
+
+		err = repo.SaveBulk(context.Background(), zettels...)
+		require.Equal(t, err, nil, "failed to create zettels")
+
+		duplicates, err := repo.DuplicateSlugs(context.Background())
+		require.Equal(t, err, nil, "failed to query duplicate slugs")
+		assert.Equal(t, len(duplicates), 0, "should have no duplicates")
+	})
+
+	t.Run("full text index is consistent", func(t *testing.T) {
+		db := sqltest.CreateDatabase(t, cfg)
+		repo, err := NewZettelRepository(db, cfg)
+		require.Equal(t, err, nil, "failed to create the repository")
+
+		createZettel(t, repo, &model.Zettel{ID: "1", Title: "Testing Zettel"})
+
+		err = repo.CheckIndex(context.Background())
+		assert.Equal(t, err, nil, "index should be consistent")
+
+		err = repo.RebuildIndex(context.Background())
+		assert.Equal(t, err, nil, "failed to rebuild index")
+	})
+}
+
 func createZettel(t *testing.T, repo ZettelRepository, z *model.Zettel) {
 	err := repo.Save(context.Background(), z)
 	require.Equal(t, err, nil, "failed to create zettel")
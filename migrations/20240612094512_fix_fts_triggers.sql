@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+-- external content fts5 tables need the old values on 'delete', otherwise the
+-- tokens of the old row stay in the index and it drifts from the zettel table
+drop trigger zettel_after_update;
+drop trigger zettel_after_delete;
+
+create trigger zettel_after_update after update on zettel begin
+  insert into zettel_fts(zettel_fts, rowid, id, title, content, path)
+    values('delete', old.id, old.id, old.title, old.content, old.path);
+  insert into zettel_fts(rowid, id, title, content, path)
+    values (new.id, new.id, new.title, new.content, new.path);
+end;
+
+create trigger zettel_after_delete after delete on zettel begin
+  insert into zettel_fts(zettel_fts, rowid, id, title, content, path)
+    values('delete', old.id, old.id, old.title, old.content, old.path);
+end;
+
+insert into zettel_fts(zettel_fts) values ('rebuild');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop trigger zettel_after_update;
+drop trigger zettel_after_delete;
+
+create trigger zettel_after_update after update on zettel begin
+  insert into zettel_fts(zettel_fts, rowid)
+    values('delete', old.id);
+  insert into zettel_fts(rowid, id, title, content, path)
+    values (new.id, new.id, new.title, new.content, new.path);
+end;
+
+create trigger zettel_after_delete after delete on zettel begin
+  insert into zettel_fts(zettel_fts, rowid)
+    values('delete', old.id);
+end;
+-- +goose StatementEnd
@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+-- disambiguate the slugs that are already duplicated, the oldest zettel keeps
+-- the bare slug and the next ones get the first free -2, -3, ... suffixes,
+-- skipping the slugs that are already taken
+create temp table slug_rank as
+  select id, slug, row_number() over (partition by slug order by created_at, id) as n
+  from zettel;
+
+-- enough suffixes for every duplicate, even if all the slug-* are taken
+create temp table slug_free as
+  with recursive suffix (slug, k, last) as (
+    select r.slug, 2, count(*) + (select count(*) from zettel as z where z.slug like r.slug || '-%')
+    from slug_rank as r
+    group by r.slug
+    having count(*) > 1
+    union all
+    select slug, k + 1, last from suffix where k < last
+  )
+  select slug, slug || '-' || k as free, row_number() over (partition by slug order by k) + 1 as n
+  from suffix
+  where slug || '-' || k not in (select slug from zettel);
+
+update zettel
+set slug = (
+  select f.free from slug_rank as r
+  inner join slug_free as f on f.slug = r.slug and f.n = r.n
+  where r.id = zettel.id
+)
+where id in (select id from slug_rank where n > 1);
+
+drop table slug_free;
+drop table slug_rank;
+
+drop index idx_unique_slug;
+create unique index idx_unique_slug on zettel (slug);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index idx_unique_slug;
+create unique index idx_unique_slug on zettel (slug, id);
+-- +goose StatementEnd
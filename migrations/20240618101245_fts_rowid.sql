@@ -0,0 +1,69 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the index used the zettel id as its rowid, which only works for numeric ids,
+-- use the implicit rowid of the zettel table instead
+drop trigger zettel_after_insert;
+drop trigger zettel_after_update;
+drop trigger zettel_after_delete;
+drop table zettel_fts;
+
+create virtual table zettel_fts
+  using fts5(id, title, content, path, tokenize = porter, content = 'zettel');
+
+create trigger zettel_after_insert after insert on zettel begin
+  insert into zettel_fts(rowid, id, title, content, path)
+    values (new.rowid, new.id, new.title, new.content, new.path);
+end;
+
+create trigger zettel_after_update after update on zettel begin
+  insert into zettel_fts(zettel_fts, rowid, id, title, content, path)
+    values('delete', old.rowid, old.id, old.title, old.content, old.path);
+  insert into zettel_fts(rowid, id, title, content, path)
+    values (new.rowid, new.id, new.title, new.content, new.path);
+end;
+
+create trigger zettel_after_delete after delete on zettel begin
+  insert into zettel_fts(zettel_fts, rowid, id, title, content, path)
+    values('delete', old.rowid, old.id, old.title, old.content, old.path);
+end;
+
+insert into zettel_fts(zettel_fts) values ('rebuild');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- the previous index is keyed on the zettel id, so it can't hold the ulid or
+-- folgezettel ids, refuse to go back instead of silently dropping them
+create temp table fts_rowid_down (
+    id text not null constraint numeric_ids_only check (id <> '' and id not glob '*[^0-9]*')
+) strict;
+insert into fts_rowid_down select id from zettel;
+drop table fts_rowid_down;
+
+drop trigger zettel_after_insert;
+drop trigger zettel_after_update;
+drop trigger zettel_after_delete;
+drop table zettel_fts;
+
+create virtual table zettel_fts
+  using fts5(id, title, content, path, tokenize = porter, content = 'zettel', content_rowid = 'id');
+
+create trigger zettel_after_insert after insert on zettel begin
+  insert into zettel_fts(rowid, id, title, content, path)
+    values (new.id, new.id, new.title, new.content, new.path);
+end;
+
+create trigger zettel_after_update after update on zettel begin
+  insert into zettel_fts(zettel_fts, rowid, id, title, content, path)
+    values('delete', old.id, old.id, old.title, old.content, old.path);
+  insert into zettel_fts(rowid, id, title, content, path)
+    values (new.id, new.id, new.title, new.content, new.path);
+end;
+
+create trigger zettel_after_delete after delete on zettel begin
+  insert into zettel_fts(zettel_fts, rowid, id, title, content, path)
+    values('delete', old.id, old.id, old.title, old.content, old.path);
+end;
+
+insert into zettel_fts(zettel_fts) values ('rebuild');
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+create table trash (
+    id text not null primary key,
+    title text not null,
+    slug text not null,
+    path text not null, -- path before being removed
+    type text not null,
+    content text not null,
+    trash_path text not null,
+    created_at text not null,
+    updated_at text not null,
+    removed_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')) -- use ISO8601/RFC3339
+) strict;
+
+create index trash_removed_idx on trash (removed_at);
+
+-- links of the removed zettels in both directions, no foreign keys since the
+-- other end might be removed as well
+create table trash_link (
+    zettel_id text not null,
+    link_id text not null,
+    created_at text not null,
+
+    primary key (zettel_id, link_id)
+) strict;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table trash_link;
+drop table trash;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- spaced repetition schedule of the permanent zettels, see `zet review`
+create table review (
+    zettel_id text not null primary key,
+    repetitions integer not null default 0, -- successful reviews in a row
+    interval integer not null default 0, -- days until the next review
+    ease real not null default 2.5,
+    due_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')), -- use ISO8601/RFC3339
+    reviewed_at text,
+    created_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+    updated_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+
+    foreign key (zettel_id) references zettel(id) on delete cascade
+) strict;
+
+create index review_due_idx on review (due_at);
+
+create trigger review_updated_timestamp after update on review begin
+  update review set updated_at = strftime('%Y-%m-%dT%H:%M:%fZ') where zettel_id = old.zettel_id;
+end;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop trigger review_updated_timestamp;
+drop table review;
+-- +goose StatementEnd
@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+-- append-only log of what happened to the zettels, it replaces the history
+-- table that only kept the last time a zettel was opened. The events outlive
+-- their zettels, so the removed ones are still in the log.
+create table event (
+    id integer primary key autoincrement,
+    zettel_id text not null,
+    kind text not null check (kind in ('opened', 'saved', 'created', 'promoted', 'removed')),
+    session text not null default '', -- groups the events of an editor session
+    created_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')) -- use ISO8601/RFC3339
+) strict;
+
+create index event_zettel_idx on event (zettel_id, created_at);
+create index event_created_idx on event (created_at);
+
+-- the history was written on every save, it keeps the first and the last
+-- save of each zettel
+insert into event (zettel_id, kind, created_at)
+select zettel_id, 'saved', created_at from history;
+
+insert into event (zettel_id, kind, created_at)
+select zettel_id, 'saved', updated_at from history where updated_at != created_at;
+
+drop table history;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+create table history (
+    zettel_id text not null,
+    updated_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')), -- use ISO8601/RFC3339
+    created_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')), -- use ISO8601/RFC3339
+
+    primary key (zettel_id),
+    foreign key (zettel_id) references zettel(id) on delete cascade
+) strict;
+
+create index history_created_idx on history (created_at);
+
+insert into history (zettel_id, created_at, updated_at)
+select zettel_id, min(created_at), max(created_at) from event
+where zettel_id in (select id from zettel)
+group by zettel_id;
+
+drop table event;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- old slugs of a zettel, a merged zettel keeps answering to the slug of the
+-- zettel merged into it, see `zet merge`
+create table alias (
+    slug text not null primary key,
+    zettel_id text not null,
+    created_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')), -- use ISO8601/RFC3339
+
+    foreign key (zettel_id) references zettel(id) on delete cascade
+) strict;
+
+create index alias_zettel_idx on alias (zettel_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index alias_zettel_idx;
+drop table alias;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- archiving a fleet zettel is an event too, sqlite cannot change the check of
+-- a column so the table is rebuilt
+create table event_new (
+    id integer primary key autoincrement,
+    zettel_id text not null,
+    kind text not null check (kind in ('opened', 'saved', 'created', 'promoted', 'archived', 'removed')),
+    session text not null default '', -- groups the events of an editor session
+    created_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')) -- use ISO8601/RFC3339
+) strict;
+
+insert into event_new (id, zettel_id, kind, session, created_at)
+select id, zettel_id, kind, session, created_at from event;
+
+drop table event;
+alter table event_new rename to event;
+
+create index event_zettel_idx on event (zettel_id, created_at);
+create index event_created_idx on event (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+create table event_old (
+    id integer primary key autoincrement,
+    zettel_id text not null,
+    kind text not null check (kind in ('opened', 'saved', 'created', 'promoted', 'removed')),
+    session text not null default '', -- groups the events of an editor session
+    created_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')) -- use ISO8601/RFC3339
+) strict;
+
+insert into event_old (id, zettel_id, kind, session, created_at)
+select id, zettel_id, kind, session, created_at from event where kind != 'archived';
+
+drop table event;
+alter table event_old rename to event;
+
+create index event_zettel_idx on event (zettel_id, created_at);
+create index event_created_idx on event (created_at);
+-- +goose StatementEnd
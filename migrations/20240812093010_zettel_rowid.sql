@@ -0,0 +1,139 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the implicit rowid of a table without an integer primary key may be
+-- renumbered by a vacuum, which breaks the search index keyed on it, so give
+-- the zettel table an explicit rowid column and key the index on it
+drop trigger zettel_after_insert;
+drop trigger zettel_after_update;
+drop trigger zettel_after_delete;
+drop table zettel_fts;
+
+create table zettel_new (
+    rowid integer primary key,
+    id text not null unique,
+    title text not null,
+    path text not null,
+    type text not null,
+    content text not null,
+    created_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+    updated_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+    slug text
+) strict;
+
+insert into zettel_new (id, title, path, type, content, created_at, updated_at, slug)
+  select id, title, path, type, content, created_at, updated_at, slug from zettel order by created_at, id;
+
+-- dropping the zettel table cascades to the rows that reference it
+create temp table link_backup as select * from link;
+create temp table review_backup as select * from review;
+create temp table alias_backup as select * from alias;
+
+drop table zettel;
+alter table zettel_new rename to zettel;
+
+insert into link select * from link_backup;
+insert into review select * from review_backup;
+insert into alias select * from alias_backup;
+drop table link_backup;
+drop table review_backup;
+drop table alias_backup;
+
+create index zettel_created_idx on zettel (created_at);
+create index zettel_path_idx on zettel (path);
+create unique index idx_unique_slug on zettel (slug);
+
+create trigger zettel_updated_timestamp after update on zettel begin
+  -- use ISO8601/RFC3339
+  update zettel set updated_at = strftime('%Y-%m-%dT%H:%M:%fZ') where id = old.id;
+end;
+
+create virtual table zettel_fts
+  using fts5(id, title, content, path, tokenize = porter, content = 'zettel', content_rowid = 'rowid');
+
+create trigger zettel_after_insert after insert on zettel begin
+  insert into zettel_fts(rowid, id, title, content, path)
+    values (new.rowid, new.id, new.title, new.content, new.path);
+end;
+
+create trigger zettel_after_update after update on zettel begin
+  insert into zettel_fts(zettel_fts, rowid, id, title, content, path)
+    values('delete', old.rowid, old.id, old.title, old.content, old.path);
+  insert into zettel_fts(rowid, id, title, content, path)
+    values (new.rowid, new.id, new.title, new.content, new.path);
+end;
+
+create trigger zettel_after_delete after delete on zettel begin
+  insert into zettel_fts(zettel_fts, rowid, id, title, content, path)
+    values('delete', old.rowid, old.id, old.title, old.content, old.path);
+end;
+
+insert into zettel_fts(zettel_fts) values ('rebuild');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop trigger zettel_after_insert;
+drop trigger zettel_after_update;
+drop trigger zettel_after_delete;
+drop table zettel_fts;
+
+create table zettel_old (
+    id text not null primary key,
+    title text not null,
+    path text not null,
+    type text not null,
+    content text not null,
+    created_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+    updated_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+    slug text
+) strict;
+
+insert into zettel_old (id, title, path, type, content, created_at, updated_at, slug)
+  select id, title, path, type, content, created_at, updated_at, slug from zettel;
+
+-- dropping the zettel table cascades to the rows that reference it
+create temp table link_backup as select * from link;
+create temp table review_backup as select * from review;
+create temp table alias_backup as select * from alias;
+
+drop table zettel;
+alter table zettel_old rename to zettel;
+
+insert into link select * from link_backup;
+insert into review select * from review_backup;
+insert into alias select * from alias_backup;
+drop table link_backup;
+drop table review_backup;
+drop table alias_backup;
+
+create index zettel_created_idx on zettel (created_at);
+create index zettel_path_idx on zettel (path);
+create unique index idx_unique_slug on zettel (slug);
+
+create trigger zettel_updated_timestamp after update on zettel begin
+  -- use ISO8601/RFC3339
+  update zettel set updated_at = strftime('%Y-%m-%dT%H:%M:%fZ') where id = old.id;
+end;
+
+create virtual table zettel_fts
+  using fts5(id, title, content, path, tokenize = porter, content = 'zettel');
+
+create trigger zettel_after_insert after insert on zettel begin
+  insert into zettel_fts(rowid, id, title, content, path)
+    values (new.rowid, new.id, new.title, new.content, new.path);
+end;
+
+create trigger zettel_after_update after update on zettel begin
+  insert into zettel_fts(zettel_fts, rowid, id, title, content, path)
+    values('delete', old.rowid, old.id, old.title, old.content, old.path);
+  insert into zettel_fts(rowid, id, title, content, path)
+    values (new.rowid, new.id, new.title, new.content, new.path);
+end;
+
+create trigger zettel_after_delete after delete on zettel begin
+  insert into zettel_fts(zettel_fts, rowid, id, title, content, path)
+    values('delete', old.rowid, old.id, old.title, old.content, old.path);
+end;
+
+insert into zettel_fts(zettel_fts) values ('rebuild');
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- schedule of the removed zettels, so it's back when they are restored
+create table trash_review (
+    zettel_id text not null primary key,
+    repetitions integer not null,
+    interval integer not null,
+    ease real not null,
+    due_at text not null,
+    reviewed_at text,
+    created_at text not null,
+    updated_at text not null
+) strict;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table trash_review;
+-- +goose StatementEnd
@@ -3,12 +3,13 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"io"
 	"log"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 type Database struct {
@@ -99,6 +100,26 @@ func (t *Transaction) Rollback() error {
 	return t.Tx.Rollback()
 }
 
+// IsBusy reports whether the error is sqlite giving up on a lock held by
+// another connection, e.g. a running zet lsp or zet serve
+func IsBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
+
+// IsQueryError reports whether sqlite refused the statement itself, e.g. an
+// invalid fts5 match expression
+func IsQueryError(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrError
+}
+
 // func CheckForLocks(db *sqlx.DB) error {
 //     type LockStatus struct {
 //         Database string `db:"database"`
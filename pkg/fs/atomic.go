@@ -0,0 +1,88 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteAtomic replaces the content of a file by writing it to a temporary file
+// in the same directory and renaming it over the original. An interrupted
+// write leaves the original untouched. The permissions of the original file
+// are preserved.
+func WriteAtomic(path string, text string) error {
+	perm := os.FileMode(DefaultFilePerms)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	// remove the temporary file if anything goes wrong, after the rename this
+	// is a no-op
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(text); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// CreateExclusive creates a new file with the given content, failing with an
+// error wrapping os.ErrExist if the file already exists.
+func CreateExclusive(path string, text string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, DefaultFilePerms)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+
+	return f.Close()
+}
+
+// syncDir flushes the directory entry, so a rename survives a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return fmt.Errorf("error: failed to sync directory %s: %w", dir, err)
+	}
+
+	return d.Close()
+}
@@ -0,0 +1,87 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	t.Run("replaces the content and preserves the permissions", func(t *testing.T) {
+		Mkdir("/tmp/test_atomic")
+		t.Cleanup(func() {
+			RemoveAll("/tmp/test_atomic")
+		})
+
+		path := "/tmp/test_atomic/file.md"
+
+		err := CreateExclusive(path, "# Title\n")
+		require.Equal(t, err, nil, "failed to create file")
+
+		err = os.Chmod(path, 0600)
+		require.Equal(t, err, nil, "failed to chmod file")
+
+		err = WriteAtomic(path, "# Another Title\n")
+		require.Equal(t, err, nil, "failed to write file")
+
+		content, _ := Read(path)
+		assert.Equal(t, content, "# Another Title\n", "content should be replaced")
+
+		info, _ := os.Stat(path)
+		assert.Equal(t, info.Mode().Perm(), os.FileMode(0600), "permissions should be preserved")
+
+		files := List("/tmp/test_atomic")
+		assert.Equal(t, len(files), 1, "temporary files should be removed")
+	})
+
+	t.Run("exclusive create does not overwrite", func(t *testing.T) {
+		Mkdir("/tmp/test_atomic")
+		t.Cleanup(func() {
+			RemoveAll("/tmp/test_atomic")
+		})
+
+		path := "/tmp/test_atomic/file.md"
+
+		err := CreateExclusive(path, "# Title\n")
+		require.Equal(t, err, nil, "failed to create file")
+
+		err = CreateExclusive(path, "# Another Title\n")
+		assert.Equal(t, errors.Is(err, os.ErrExist), true, "file should already exist")
+
+		err = CreateExclusive("/tmp/test_atomic/other.md", "")
+		require.Equal(t, err, nil, "failed to create file")
+
+		err = Move("/tmp/test_atomic/other.md", path)
+		assert.Equal(t, errors.Is(err, os.ErrExist), true, "move should not overwrite")
+
+		content, _ := Read(path)
+		assert.Equal(t, content, "# Title\n", "content should be untouched")
+	})
+
+	t.Run("inserting lines preserves the trailing newline", func(t *testing.T) {
+		Mkdir("/tmp/test_atomic")
+		t.Cleanup(func() {
+			RemoveAll("/tmp/test_atomic")
+		})
+
+		path := "/tmp/test_atomic/file.md"
+
+		err := CreateExclusive(path, "# Title\nlast line")
+		require.Equal(t, err, nil, "failed to create file")
+
+		err = InsertLineAtIndex(path, "first line", 1)
+		require.Equal(t, err, nil, "failed to insert line")
+
+		content, _ := Read(path)
+		assert.Equal(t, content, "# Title\nfirst line\nlast line", "no trailing newline should be added")
+
+		err = InsertLine(path, "new last line")
+		require.Equal(t, err, nil, "failed to insert line")
+
+		content, _ = Read(path)
+		assert.Equal(t, content, "# Title\nfirst line\nlast line\nnew last line\n", "line should be appended")
+	})
+}
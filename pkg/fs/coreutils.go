@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"os"
@@ -13,6 +14,9 @@ import (
 
 const (
 	DefaultPerms = 0600
+
+	// DefaultFilePerms are the permissions of the files created by zet
+	DefaultFilePerms = 0644
 )
 
 func Create(path string) error {
@@ -61,9 +65,10 @@ func ReadLines(filePath string) ([]string, error) {
 }
 
 // Write writes content to a file. It will append to the file if it already
-// exists and create it if it doesn't.
+// exists and create it if it doesn't. Use WriteAtomic to replace the content of
+// a zettel and CreateExclusive to create a new one.
 func Write(path string, text string) error {
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, DefaultFilePerms)
 	if err != nil {
 		return err
 	}
@@ -116,41 +121,51 @@ func Exists(path string) bool {
 	return !errors.Is(err, fs.ErrNotExist)
 }
 
+// IsDir reports whether the path exists and is a directory
+func IsDir(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return info.IsDir()
+}
+
+// InsertLine appends a line to the end of a file, atomically
 func InsertLine(path, newLine string) error {
-	lines, err := ReadLines(path)
+	content, err := Read(path)
 	if err != nil {
 		return err
 	}
 
-	fileContent := ""
-	for _, line := range lines {
-		fileContent += line
-		fileContent += "\n"
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
 	}
+	content += newLine + "\n"
 
-	fileContent += newLine
-	fileContent += "\n"
-
-	return os.WriteFile(path, []byte(fileContent), 0644)
+	return WriteAtomic(path, content)
 }
 
+// InsertLineAtIndex inserts a line before the line at the given index,
+// atomically. The trailing newline of the file is preserved.
 func InsertLineAtIndex(path, newLine string, index int) error {
-	lines, err := ReadLines(path)
+	content, err := Read(path)
 	if err != nil {
 		return err
 	}
 
-	fileContent := ""
-	for i, line := range lines {
-		if i == index {
-			fileContent += newLine
-			fileContent += "\n"
-		}
-		fileContent += line
-		fileContent += "\n"
+	trailing := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+
+	if index >= 0 && index < len(lines) {
+		lines = append(lines[:index], append([]string{newLine}, lines[index:]...)...)
+	}
+
+	content = strings.Join(lines, "\n")
+	if trailing {
+		content += "\n"
 	}
 
-	return os.WriteFile(path, []byte(fileContent), 0644)
+	return WriteAtomic(path, content)
 }
 
 func Input(prompt string) string {
@@ -180,11 +195,43 @@ func Open(path string) error {
 	return nil
 }
 
-// Move moves a file from one path to another
+// Move moves a file from one path to another, it fails with an error wrapping
+// os.ErrExist instead of overwriting an existing file.
 func Move(oldPath, newPath string) error {
+	if oldPath != newPath && Exists(newPath) {
+		return fmt.Errorf("error: cannot move %s to %s: %w", oldPath, newPath, os.ErrExist)
+	}
 	return os.Rename(oldPath, newPath)
 }
 
+// Copy copies a file to a new path, creating its parent directories. Like
+// Move, it fails with an error wrapping os.ErrExist instead of overwriting an
+// existing file.
+func Copy(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, DefaultFilePerms)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+
+	return out.Close()
+}
+
 // Editor opens a file with the default $EDITOR from the user system
 func Editor(path string) error {
 	editor := os.Getenv("EDITOR")
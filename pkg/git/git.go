@@ -0,0 +1,301 @@
+// Package git runs the git commands zet needs on a working tree, by calling
+// the git binary.
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotRepository = errors.New("error: not a git repository")
+	// ErrConflict is returned by Pull when the merge stops with conflicts, the
+	// merge is left in progress so it can be resolved
+	ErrConflict = errors.New("error: merge conflict")
+)
+
+// Status codes of a change, as in `git status --porcelain`
+const (
+	StatusAdded    = "A"
+	StatusModified = "M"
+	StatusDeleted  = "D"
+	StatusRenamed  = "R"
+)
+
+// Change is a changed file of the working tree
+type Change struct {
+	Status string
+	// relative to the root of the working tree
+	Path string
+	// the previous path of a renamed file
+	From string
+}
+
+type Repo struct {
+	// root of the working tree
+	Dir string
+	// paths left out of Status and CommitAll, relative to the root
+	Exclude []string
+}
+
+// Open returns the repository the directory belongs to
+func Open(dir string) (*Repo, error) {
+	out, err := run(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotRepository, dir)
+	}
+
+	return &Repo{Dir: strings.TrimSpace(out)}, nil
+}
+
+// Run runs a git command in the working tree, returning its output
+func (r *Repo) Run(args ...string) (string, error) {
+	return run(r.Dir, args...)
+}
+
+// Status stages every change of the working tree, including the untracked
+// files, and returns them. Staging them is what lets git detect the renames.
+func (r *Repo) Status() ([]*Change, error) {
+	if _, err := r.Run(append([]string{"add", "--all"}, r.pathspec()...)...); err != nil {
+		return nil, err
+	}
+
+	out, err := r.Run(append([]string{"status", "--porcelain", "-z", "--untracked-files=all"}, r.pathspec()...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*Change
+
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+
+		x, y, path := entry[0], entry[1], entry[3:]
+
+		change := &Change{Path: path}
+		switch {
+		case x == 'R' || y == 'R':
+			change.Status = StatusRenamed
+			// the previous path is the next entry
+			i++
+			if i < len(entries) {
+				change.From = entries[i]
+			}
+		case x == 'D' || y == 'D':
+			change.Status = StatusDeleted
+		case x == 'A' || y == 'A' || x == '?':
+			change.Status = StatusAdded
+		default:
+			change.Status = StatusModified
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// CommitAll stages every change and commits it with the message
+func (r *Repo) CommitAll(message string) error {
+	if _, err := r.Run(append([]string{"add", "--all"}, r.pathspec()...)...); err != nil {
+		return err
+	}
+
+	_, err := r.Run("commit", "--quiet", "--message", message)
+	return err
+}
+
+// pathspec matches the whole working tree but the excluded paths
+func (r *Repo) pathspec() []string {
+	spec := []string{"--", "."}
+	for _, path := range r.Exclude {
+		spec = append(spec, ":(exclude)"+path)
+	}
+	return spec
+}
+
+// HasUpstream reports whether the current branch tracks a remote branch
+func (r *Repo) HasUpstream() bool {
+	_, err := r.Run("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	return err == nil
+}
+
+// Pull merges the upstream branch. When the merge stops with conflicts, it
+// returns the conflicting files with an error wrapping ErrConflict.
+func (r *Repo) Pull() ([]string, error) {
+	_, err := r.Run("pull", "--no-rebase", "--no-edit", "--quiet")
+	if err == nil {
+		return nil, nil
+	}
+
+	conflicts, cerr := r.Conflicts()
+	if cerr != nil || len(conflicts) == 0 {
+		return nil, err
+	}
+
+	return conflicts, fmt.Errorf("%w: %s", ErrConflict, strings.Join(conflicts, ", "))
+}
+
+// Conflicts returns the unmerged files of the working tree
+func (r *Repo) Conflicts() ([]string, error) {
+	out, err := r.Run("diff", "--name-only", "--diff-filter=U", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	for _, path := range strings.Split(out, "\x00") {
+		if path != "" {
+			conflicts = append(conflicts, path)
+		}
+	}
+
+	return conflicts, nil
+}
+
+func (r *Repo) Push() error {
+	_, err := r.Run("push", "--quiet")
+	return err
+}
+
+func run(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("error: git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// Commit is a commit that changed a file
+type Commit struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+	// path of the file in the commit, it changes when the file was renamed
+	Path string `json:"path"`
+	// A, M, D or R
+	Status string `json:"status"`
+}
+
+// Rel returns the path relative to the root of the working tree
+func (r *Repo) Rel(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	// the root is resolved by git, resolve the directory of the path as well
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		abs = filepath.Join(dir, filepath.Base(abs))
+	}
+
+	rel, err := filepath.Rel(r.Dir, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("error: %s is outside of the repository %s", path, r.Dir)
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+// Log returns the commits that changed the file, newest first, following its
+// renames
+func (r *Repo) Log(path string) ([]*Commit, error) {
+	out, err := r.Run("log", "--follow", "--name-status", "-z", "--format=%x1e%H%x1f%an%x1f%aI%x1f%s", "--", path)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []*Commit
+	for _, record := range strings.Split(out, "\x1e") {
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+
+		header, files, _ := strings.Cut(record, "\n")
+		fields := strings.Split(strings.TrimRight(header, "\x00"), "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+
+		date, err := time.Parse(time.RFC3339, fields[2])
+		if err != nil {
+			return nil, err
+		}
+
+		commit := &Commit{Hash: fields[0], Author: fields[1], Date: date, Subject: fields[3]}
+
+		// "M\x00path\x00" or "R100\x00old\x00new\x00"
+		entries := strings.Split(strings.Trim(files, "\n\x00"), "\x00")
+		if len(entries) >= 2 {
+			commit.Status = entries[0][:1]
+			commit.Path = entries[len(entries)-1]
+		}
+
+		commits = append(commits, commit)
+	}
+
+	return commits, nil
+}
+
+// PathAt returns the path the file had at the revision, following its renames
+func (r *Repo) PathAt(path string, rev string) (string, error) {
+	commits, err := r.Log(path)
+	if err != nil {
+		return "", err
+	}
+
+	for _, commit := range commits {
+		// the newest change of the file that is part of the revision
+		if _, err := r.Run("merge-base", "--is-ancestor", commit.Hash, rev); err != nil {
+			continue
+		}
+		if commit.Status == StatusDeleted {
+			break
+		}
+		return commit.Path, nil
+	}
+
+	return "", fmt.Errorf("error: %s does not exist at %s", path, rev)
+}
+
+// Show returns the content of the file at the revision, following its renames
+func (r *Repo) Show(path string, rev string) (string, error) {
+	old, err := r.PathAt(path, rev)
+	if err != nil {
+		return "", err
+	}
+
+	return r.Run("show", rev+":"+old)
+}
+
+// Diff returns the changes of the file between the revision and the working
+// tree, following its renames
+func (r *Repo) Diff(path string, rev string) (string, error) {
+	old, err := r.PathAt(path, rev)
+	if err != nil {
+		return "", err
+	}
+
+	if old == path {
+		return r.Run("diff", rev, "--", path)
+	}
+
+	return r.Run("diff", "--find-renames", rev, "--", old, path)
+}
@@ -0,0 +1,59 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/muxit-studio/test/assert"
+	"github.com/muxit-studio/test/require"
+)
+
+func TestStatus(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "zet")
+	t.Setenv("GIT_AUTHOR_EMAIL", "zet@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "zet")
+	t.Setenv("GIT_COMMITTER_EMAIL", "zet@example.com")
+
+	dir := t.TempDir()
+	out, err := exec.Command("git", "init", "--quiet", dir).CombinedOutput()
+	require.Equal(t, err, nil, string(out))
+
+	write := func(path, content string) {
+		path = filepath.Join(dir, path)
+		require.Equal(t, os.MkdirAll(filepath.Dir(path), 0755), nil, "failed to create dir")
+		require.Equal(t, os.WriteFile(path, []byte(content), 0644), nil, "failed to write file")
+	}
+
+	write("fleet/1.md", "# One\n\nA long enough content to be detected as a rename\n")
+	write("fleet/2.md", "# Two\n")
+	write("fleet/3.md", "# Three\n")
+
+	repo, err := Open(filepath.Join(dir, "fleet"))
+	require.Equal(t, err, nil, "failed to open repository")
+	require.Equal(t, repo.CommitAll("init"), nil, "failed to commit")
+
+	require.Equal(t, os.MkdirAll(filepath.Join(dir, "permanent"), 0755), nil, "failed to create dir")
+	require.Equal(t, os.Rename(filepath.Join(dir, "fleet/1.md"), filepath.Join(dir, "permanent/1.md")), nil, "failed to move")
+	write("fleet/2.md", "# Two\n\nEdited\n")
+	require.Equal(t, os.Remove(filepath.Join(dir, "fleet/3.md")), nil, "failed to remove")
+	write("fleet/4.md", "# Four\n")
+
+	changes, err := repo.Status()
+	require.Equal(t, err, nil, "failed to get status")
+
+	statuses := make(map[string]*Change)
+	for _, c := range changes {
+		statuses[c.Path] = c
+	}
+
+	require.Equal(t, len(changes), 4, "every change should be listed")
+	assert.Equal(t, statuses["permanent/1.md"].Status, StatusRenamed, "moved file should be a rename")
+	assert.Equal(t, statuses["permanent/1.md"].From, "fleet/1.md", "rename should keep the previous path")
+	assert.Equal(t, statuses["fleet/2.md"].Status, StatusModified, "edited file should be modified")
+	assert.Equal(t, statuses["fleet/3.md"].Status, StatusDeleted, "removed file should be deleted")
+	assert.Equal(t, statuses["fleet/4.md"].Status, StatusAdded, "new file should be added")
+}
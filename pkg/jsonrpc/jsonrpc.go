@@ -0,0 +1,235 @@
+// Package jsonrpc implements a minimal JSON-RPC 2.0 connection, framed either
+// with Content-Length headers (like LSP) or with one message per line.
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const Version = "2.0"
+
+// Error codes defined by the specification
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// IsNotification reports whether the request expects no response
+func (r *Request) IsNotification() bool {
+	return len(r.ID) == 0
+}
+
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc: %d %s", e.Code, e.Message)
+}
+
+// Errorf returns an *Error with the given code
+func Errorf(code int, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Handler handles a request, the result is marshalled into the response. A
+// returned *Error is sent as is, any other error as an internal error.
+type Handler func(ctx context.Context, req *Request) (any, error)
+
+// Stream reads and writes framed messages
+type Stream interface {
+	Read() ([]byte, error)
+	Write(msg []byte) error
+}
+
+type headerStream struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// NewHeaderStream frames the messages with a Content-Length header, as the
+// Language Server Protocol does.
+func NewHeaderStream(r io.Reader, w io.Writer) Stream {
+	return &headerStream{r: bufio.NewReader(r), w: w}
+}
+
+func (s *headerStream) Read() ([]byte, error) {
+	header, err := textproto.NewReader(s.r).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("jsonrpc: invalid Content-Length: %w", err)
+	}
+
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(s.r, msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (s *headerStream) Write(msg []byte) error {
+	if _, err := fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n", len(msg)); err != nil {
+		return err
+	}
+	_, err := s.w.Write(msg)
+	return err
+}
+
+type lineStream struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// NewLineStream frames the messages as newline delimited JSON
+func NewLineStream(r io.Reader, w io.Writer) Stream {
+	return &lineStream{r: bufio.NewReader(r), w: w}
+}
+
+func (s *lineStream) Read() ([]byte, error) {
+	for {
+		line, err := s.r.ReadString('\n')
+		if strings.TrimSpace(line) != "" {
+			return []byte(line), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *lineStream) Write(msg []byte) error {
+	_, err := s.w.Write(append(msg, '\n'))
+	return err
+}
+
+// Conn serves the requests of a stream, one at a time
+type Conn struct {
+	stream Stream
+	mu     sync.Mutex
+}
+
+func NewConn(stream Stream) *Conn {
+	return &Conn{stream: stream}
+}
+
+// Serve reads requests until the stream is closed or the context is
+// cancelled. Messages without a method (responses) are ignored.
+func (c *Conn) Serve(ctx context.Context, handler Handler) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil
+		}
+
+		msg, err := c.stream.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		req := &Request{}
+		if err := json.Unmarshal(msg, req); err != nil {
+			if err := c.reply(nil, nil, Errorf(CodeParseError, "%v", err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if req.Method == "" {
+			continue
+		}
+
+		result, err := handler(ctx, req)
+		if req.IsNotification() {
+			continue
+		}
+
+		if err := c.reply(req.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+// Notify sends a notification, it is safe to call while serving
+func (c *Conn) Notify(method string, params any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	return c.write(&Request{JSONRPC: Version, Method: method, Params: raw})
+}
+
+func (c *Conn) reply(id json.RawMessage, result any, err error) error {
+	res := &Response{JSONRPC: Version, ID: id}
+	if id == nil {
+		res.ID = json.RawMessage("null")
+	}
+
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = Errorf(CodeInternalError, "%v", err)
+		}
+		res.Error = rpcErr
+		return c.write(res)
+	}
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		res.Error = Errorf(CodeInternalError, "%v", err)
+		return c.write(res)
+	}
+	res.Result = raw
+
+	return c.write(res)
+}
+
+func (c *Conn) write(v any) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stream.Write(msg)
+}